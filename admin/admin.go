@@ -0,0 +1,278 @@
+// Package admin handles the 400 range messages, ResetRequest and
+// UpdateRequest. They are only accepted when signed by the node's own
+// identifier or one of the configured admin keys, everything else gets an
+// unauthorized response.
+package admin
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/logging"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+// MaxAge is how far the timestamp of a request may be off the local time,
+// older requests could have been captured and replayed
+var MaxAge = time.Minute
+
+// Requests are the admin requests by the names the cli uses
+var Requests = map[string]message.MsgType{
+	"reject":          message.BlockRejectionRequest,
+	"detach":          message.DetachmentRequest,
+	"purge":           message.UnfrozenBlockPoolPurgeRequest,
+	"pool":            message.UnfrozenBlockPoolStatusRequest,
+	"mesh":            message.MeshStatusRequest,
+	"pause":           message.TogglePauseRequest,
+	"consensus-tally": message.ConsensusTallyStatusRequest,
+	"verifier-tally":  message.NewVerifierTallyStatusRequest,
+	"reset":           message.ResetRequest,
+	"update":          message.UpdateRequest,
+}
+
+var log = logging.Logger("admin")
+
+type Mesh interface {
+	Verifiers() []crypto.PublicKey
+}
+
+// Pool is the pool of blocks that aren't frozen yet
+type Pool interface {
+	// Purge empties the pool and returns the number of blocks removed
+	Purge() int
+	Status() []string
+}
+
+type Tally interface {
+	ConsensusTally() []string
+	NewVerifierTally() []string
+}
+
+// Handlers is safe for concurrent use. The components that don't exist
+// yet are nil and their requests are answered with "not available".
+type Handlers struct {
+	Mesh  Mesh
+	Pool  Pool
+	Tally Tally
+	// Clock is the time requests are checked against and responses are
+	// timestamped with, the system clock unless set
+	Clock clock.Clock
+	// Reset is called for a ResetRequest
+	Reset func() error
+	// Update is called for an UpdateRequest, which is only logged if nil
+	Update func()
+
+	id     crypto.PublicKey
+	admins map[crypto.PublicKey]bool
+
+	mu       sync.Mutex
+	paused   bool
+	detached bool
+	rejected map[crypto.Hash]bool
+	// seen are the signatures of the accepted requests by timestamp, so
+	// each request is handled once
+	seen map[crypto.Signature]int64
+}
+
+// New accepts requests signed by id or any of admins
+func New(id crypto.PublicKey, admins []crypto.PublicKey) *Handlers {
+	h := &Handlers{
+		Clock:    clock.System{},
+		id:       id,
+		admins:   make(map[crypto.PublicKey]bool),
+		rejected: make(map[crypto.Hash]bool),
+		seen:     make(map[crypto.Signature]int64),
+	}
+	for _, admin := range admins {
+		h.admins[admin] = true
+	}
+	return h
+}
+
+// Register handles all admin requests on s
+func (h *Handlers) Register(s *network.Server) {
+	handlers := map[message.MsgType]func(*message.Msg) []string{
+		message.BlockRejectionRequest:          h.rejectBlock,
+		message.DetachmentRequest:              h.toggleDetached,
+		message.UnfrozenBlockPoolPurgeRequest:  h.purgePool,
+		message.UnfrozenBlockPoolStatusRequest: h.poolStatus,
+		message.MeshStatusRequest:              h.meshStatus,
+		message.TogglePauseRequest:             h.togglePause,
+		message.ConsensusTallyStatusRequest:    h.consensusTally,
+		message.NewVerifierTallyStatusRequest:  h.newVerifierTally,
+		message.ResetRequest:                   h.reset,
+		message.UpdateRequest:                  h.update,
+	}
+	for msgType, f := range handlers {
+		s.Handle(msgType, h.authorized(f))
+	}
+}
+
+// authorized wraps f so it only sees requests from the node or an admin,
+// the lines it returns are sent back as a MultilineTextResponse
+func (h *Handlers) authorized(f func(*message.Msg) []string) network.HandlerFunc {
+	return func(msg *message.Msg, addr net.Addr) *message.Msg {
+		lines := []string{"unauthorized"}
+		if err := h.check(msg); err != nil {
+			log.Warn("rejected admin request", "type", msg.Type, "id", msg.ID.StringCompact(),
+				"addr", addr, "err", err)
+		} else {
+			log.Info("admin request", "type", msg.Type, "id", msg.ID.StringCompact(), "addr", addr)
+			lines = f(msg)
+		}
+
+		content := &message.MultilineTextResponseContent{Lines: lines}
+		resp := message.NewWithClock(h.Clock, int(msg.Type+1))
+		resp.Content = content.Serialize()
+		return resp
+	}
+}
+
+func (h *Handlers) check(msg *message.Msg) error {
+	if msg.ID != h.id && !h.admins[msg.ID] {
+		return fmt.Errorf("not an admin key")
+	}
+	now := h.Clock.Now().UnixNano()
+	if age := time.Duration(now - msg.Timestamp); age > MaxAge || age < -MaxAge {
+		return fmt.Errorf("timestamp off by %v", age)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sig, timestamp := range h.seen {
+		if time.Duration(now-timestamp) > MaxAge {
+			delete(h.seen, sig)
+		}
+	}
+	if _, ok := h.seen[msg.Sig]; ok {
+		return fmt.Errorf("replayed")
+	}
+	h.seen[msg.Sig] = msg.Timestamp
+	return nil
+}
+
+// Paused is true while block production and voting should be on hold
+func (h *Handlers) Paused() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.paused
+}
+
+// Detached is true while the node should stay out of the mesh
+func (h *Handlers) Detached() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.detached
+}
+
+// Rejected is true for blocks that must not be frozen or voted for
+func (h *Handlers) Rejected(hash crypto.Hash) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.rejected[hash]
+}
+
+func (h *Handlers) rejectBlock(msg *message.Msg) []string {
+	if len(msg.Content) < len(crypto.Hash{}) {
+		return []string{"a block hash is required"}
+	}
+	var hash crypto.Hash
+	copy(hash[:], msg.Content)
+
+	h.mu.Lock()
+	h.rejected[hash] = true
+	h.mu.Unlock()
+	return []string{"rejected block " + hash.String()}
+}
+
+func (h *Handlers) toggleDetached(msg *message.Msg) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.detached = !h.detached
+	return []string{fmt.Sprintf("detached: %v", h.detached)}
+}
+
+func (h *Handlers) togglePause(msg *message.Msg) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.paused = !h.paused
+	return []string{fmt.Sprintf("paused: %v", h.paused)}
+}
+
+func (h *Handlers) purgePool(msg *message.Msg) []string {
+	if h.Pool == nil {
+		return unavailable("unfrozen block pool")
+	}
+	return []string{fmt.Sprintf("purged %v blocks", h.Pool.Purge())}
+}
+
+func (h *Handlers) poolStatus(msg *message.Msg) []string {
+	if h.Pool == nil {
+		return unavailable("unfrozen block pool")
+	}
+	return h.Pool.Status()
+}
+
+func (h *Handlers) meshStatus(msg *message.Msg) []string {
+	if h.Mesh == nil {
+		return unavailable("mesh")
+	}
+	verifiers := h.Mesh.Verifiers()
+	lines := make([]string, 0, len(verifiers)+1)
+	lines = append(lines, fmt.Sprintf("%v verifiers", len(verifiers)))
+	ids := make([]string, len(verifiers))
+	for i, id := range verifiers {
+		ids[i] = id.String()
+	}
+	sort.Strings(ids)
+	return append(lines, ids...)
+}
+
+func (h *Handlers) consensusTally(msg *message.Msg) []string {
+	if h.Tally == nil {
+		return unavailable("consensus tally")
+	}
+	return h.Tally.ConsensusTally()
+}
+
+func (h *Handlers) newVerifierTally(msg *message.Msg) []string {
+	if h.Tally == nil {
+		return unavailable("new verifier tally")
+	}
+	return h.Tally.NewVerifierTally()
+}
+
+func (h *Handlers) reset(msg *message.Msg) []string {
+	if h.Reset == nil {
+		return unavailable("reset")
+	}
+	if err := h.Reset(); err != nil {
+		log.Error("reset failed", "err", err)
+		return []string{fmt.Sprintf("reset failed: %v", err)}
+	}
+	return []string{"reset"}
+}
+
+func (h *Handlers) update(msg *message.Msg) []string {
+	if h.Update == nil {
+		log.Info("update requested, updates are disabled", "id", msg.ID.StringCompact())
+		return []string{"updates are disabled"}
+	}
+	h.Update()
+	return []string{"updating"}
+}
+
+func unavailable(what string) []string {
+	return []string{what + " not available"}
+}
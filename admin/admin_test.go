@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+type testMesh []crypto.PublicKey
+
+func (m testMesh) Verifiers() []crypto.PublicKey {
+	return m
+}
+
+func startServer(t *testing.T, h *Handlers) string {
+	s := network.NewServer(crypto.GenPrivKey())
+	h.Register(s)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+	return ln.Addr().String()
+}
+
+func TestAdmin(t *testing.T) {
+	t.Parallel()
+	c := clock.NewManual(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	nodeKey := crypto.GenPrivKey()
+	adminKey := crypto.GenPrivKey()
+	h := New(nodeKey.PubKey(), []crypto.PublicKey{adminKey.PubKey()})
+	h.Clock = c
+	h.Mesh = testMesh{{2}, {1}}
+	addr := startServer(t, h)
+
+	hash := crypto.Hash{1, 2, 3}
+	var replay *message.Msg
+	tests := []struct {
+		key      crypto.PrivateKey
+		msgType  message.MsgType
+		content  []byte
+		age      time.Duration
+		expected []string
+	}{
+		{nodeKey, message.TogglePauseRequest, nil, 0, []string{"paused: true"}},
+		{adminKey, message.TogglePauseRequest, nil, 0, []string{"paused: false"}},
+		{crypto.GenPrivKey(), message.TogglePauseRequest, nil, 0, []string{"unauthorized"}},
+		{adminKey, message.TogglePauseRequest, nil, 2 * time.Minute, []string{"unauthorized"}},
+		{adminKey, message.DetachmentRequest, nil, 0, []string{"detached: true"}},
+		{adminKey, message.BlockRejectionRequest, hash[:], 0, []string{"rejected block " + hash.String()}},
+		{adminKey, message.BlockRejectionRequest, nil, 0, []string{"a block hash is required"}},
+		{adminKey, message.MeshStatusRequest, nil, 0, []string{
+			"2 verifiers",
+			crypto.PublicKey{1}.String(),
+			crypto.PublicKey{2}.String(),
+		}},
+		{adminKey, message.UnfrozenBlockPoolPurgeRequest, nil, 0, []string{"unfrozen block pool not available"}},
+		{adminKey, message.ResetRequest, nil, 0, []string{"reset not available"}},
+		{adminKey, message.UpdateRequest, nil, 0, []string{"updates are disabled"}},
+	}
+
+	for i, test := range tests {
+		msg := message.NewWithClock(c, int(test.msgType))
+		msg.Timestamp -= int64(test.age)
+		msg.Content = test.content
+		msg.Sign(test.key)
+		if i == 0 {
+			replay = msg
+		}
+
+		resp, err := network.Fetch(addr, msg)
+		if err != nil {
+			t.Fatalf("fetch failed (%v): %v", i, err)
+		}
+		if resp.Type != test.msgType+1 {
+			t.Errorf("unexpected response type %v (%v)", resp.Type, i)
+		}
+		content := &message.MultilineTextResponseContent{}
+		if err := content.Deserialize(resp.Content); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(content.Lines, test.expected) {
+			t.Errorf("expected %q, got %q (%v)", test.expected, content.Lines, i)
+		}
+	}
+
+	if h.Paused() || !h.Detached() || !h.Rejected(hash) || h.Rejected(crypto.Hash{}) {
+		t.Error("unexpected state")
+	}
+
+	// the same request is only handled once
+	resp, err := network.Fetch(addr, replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := &message.MultilineTextResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(content.Lines, []string{"unauthorized"}) || h.Paused() {
+		t.Errorf("expected the replayed request to be rejected, got %q", content.Lines)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	adminKey := crypto.GenPrivKey()
+	h := New(crypto.GenPrivKey().PubKey(), []crypto.PublicKey{adminKey.PubKey()})
+	updated := make(chan struct{}, 1)
+	h.Update = func() { updated <- struct{}{} }
+	addr := startServer(t, h)
+
+	for _, key := range []crypto.PrivateKey{crypto.GenPrivKey(), adminKey} {
+		msg := message.New(int(message.UpdateRequest))
+		msg.Sign(key)
+		if _, err := network.Fetch(addr, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(updated) != 1 {
+		t.Errorf("expected one update, got %v", len(updated))
+	}
+}
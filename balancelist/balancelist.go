@@ -3,11 +3,18 @@ package balancelist
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 
 	"github.com/qqvv/go-nyzo/crypto"
 )
 
+// MaxItems limits the item count Deserialize accepts, the count is checked
+// before anything is allocated
+var MaxItems = 1000000
+
+var ErrTooManyItems = errors.New("too many balancelist items")
+
 type List struct {
 	Height        int64              `json:"height"`
 	RolloverFees  byte               `json:"rolloverFees"`
@@ -54,6 +61,10 @@ func (list *List) Deserialize(i interface{}) error {
 		return fmt.Errorf("cannot deserialize balancelist from %#v", i)
 	}
 
+	// height (8) + rolloverfees (1)
+	if buf.Len() < 9 {
+		return fmt.Errorf("balancelist is too short")
+	}
 	binary.Read(buf, binary.BigEndian, &list.Height)
 	binary.Read(buf, binary.BigEndian, &list.RolloverFees)
 
@@ -61,6 +72,9 @@ func (list *List) Deserialize(i interface{}) error {
 	if list.Height < int64(9) {
 		prevVerifierCount = int(list.Height)
 	}
+	if prevVerifierCount > 0 && buf.Len() < prevVerifierCount*32 {
+		return fmt.Errorf("balancelist is too short for %v previous verifiers", prevVerifierCount)
+	}
 	for i := 0; i < prevVerifierCount; i++ {
 		id := crypto.PublicKey{}
 		binary.Read(buf, binary.BigEndian, &id)
@@ -68,7 +82,23 @@ func (list *List) Deserialize(i interface{}) error {
 	}
 
 	var itemCount int32
-	binary.Read(buf, binary.BigEndian, &itemCount)
+	if err := binary.Read(buf, binary.BigEndian, &itemCount); err != nil {
+		return fmt.Errorf("cannot read item count: %v", err)
+	}
+	if itemCount < 0 {
+		return fmt.Errorf("invalid item count %v", itemCount)
+	}
+	if int(itemCount) > MaxItems {
+		return fmt.Errorf("%w: %v, max %v", ErrTooManyItems, itemCount, MaxItems)
+	}
+	// pubk (32) + balance (8) + blocksuntilfee (2)
+	if buf.Len() < int(itemCount)*42 {
+		return fmt.Errorf("balancelist has %v items but only %v bytes are left", itemCount, buf.Len())
+	}
+
+	if itemCount > 0 {
+		list.Items = make([]*Item, 0, itemCount)
+	}
 	for i := 0; int32(i) < itemCount; i++ {
 		item := &Item{}
 		binary.Read(buf, binary.BigEndian, &item.ID)
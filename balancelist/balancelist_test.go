@@ -0,0 +1,143 @@
+package balancelist
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+func TestListJSON(t *testing.T) {
+	tests := []struct {
+		json  string
+		list  *List
+		valid bool
+	}{
+		{
+			json: `{"height": 1, "rolloverFees": 2,` +
+				`"prevVerifiers": ["0100000000000000-0000000000000000-0000000000000000-0000000000000000"],` +
+				`"items": [{"id": "0200000000000000000000000000000000000000000000000000000000000000",` +
+				`"balance": 10, "blocksUntilFee": 3}]}`,
+			list: &List{
+				Height:        1,
+				RolloverFees:  2,
+				PrevVerifiers: []crypto.PublicKey{{0x01}},
+				Items:         []*Item{{ID: crypto.PublicKey{0x02}, Balance: 10, BlocksUntilFee: 3}},
+			},
+			valid: true,
+		},
+		{
+			// id too short
+			json:  `{"items": [{"id": "02"}]}`,
+			valid: false,
+		},
+	}
+
+	for i, test := range tests {
+		list := &List{}
+		err := json.Unmarshal([]byte(test.json), list)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("expected unmarshalling to fail (%v)", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unmarshalling failed (%v): %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(list, test.list) {
+			t.Errorf("unexpected list (%v): %+v", i, list)
+		}
+
+		b, err := json.Marshal(list)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newList := &List{}
+		if err := json.Unmarshal(b, newList); err != nil || !reflect.DeepEqual(list, newList) {
+			t.Errorf("JSON round trip does not match (%v): %v", i, err)
+		}
+	}
+}
+
+func FuzzListDeserialize(f *testing.F) {
+	genesis := &List{Items: []*Item{{ID: crypto.PublicKey{0x01}, Balance: 10}}}
+	list := &List{Height: 20, RolloverFees: 1}
+	for i := 0; i < 9; i++ {
+		list.PrevVerifiers = append(list.PrevVerifiers, crypto.PublicKey{byte(i)})
+	}
+	list.Items = []*Item{
+		{ID: crypto.PublicKey{0x01}, Balance: 10, BlocksUntilFee: 3},
+		{ID: crypto.PublicKey{0x02}, Balance: 20, BlocksUntilFee: -1},
+	}
+
+	for _, l := range []*List{genesis, list} {
+		b := l.Serialize()
+		f.Add(b)
+		f.Add(b[:len(b)-1])
+	}
+	f.Add([]byte{})
+	// a huge item count without the items
+	f.Add([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0x7f, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		buf := bytes.NewBuffer(data)
+		list := &List{}
+		if err := list.Deserialize(buf); err != nil {
+			return
+		}
+		if len(list.Items) > len(data)/42 || len(list.PrevVerifiers) > 9 {
+			t.Fatalf("%v items and %v previous verifiers from %v bytes",
+				len(list.Items), len(list.PrevVerifiers), len(data))
+		}
+
+		expected := data[:len(data)-buf.Len()]
+		if b := list.Serialize(); !bytes.Equal(b, expected) {
+			t.Fatalf("re-encoded balancelist differs\nexpected %x\ngot      %x", expected, b)
+		}
+	})
+}
+
+func TestDeserializeLimits(t *testing.T) {
+	prevMaxItems := MaxItems
+	defer func() { MaxItems = prevMaxItems }()
+	MaxItems = 2
+
+	list := func(itemCount int32, items int) []byte {
+		buf := bytes.NewBuffer(make([]byte, 9)) // height 0 and rolloverfees
+		binary.Write(buf, binary.BigEndian, itemCount)
+		buf.Write(make([]byte, items*42))
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		data    []byte
+		valid   bool
+		tooMany bool
+	}{
+		{list(2, 2), true, false},
+		{list(0, 0), true, false},
+		{list(3, 3), false, true},
+		{list(0x7fffffff, 0), false, true},
+		{list(2, 1), false, false},
+		{list(-1, 0), false, false},
+	}
+
+	for i, test := range tests {
+		err := (&List{}).Deserialize(test.data)
+		if test.valid && err != nil {
+			t.Errorf("expected balancelist to be valid (%v): %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected balancelist to be invalid (%v)", i)
+		}
+		if errors.Is(err, ErrTooManyItems) != test.tooMany {
+			t.Errorf("unexpected error (%v): %v", i, err)
+		}
+	}
+}
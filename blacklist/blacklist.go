@@ -0,0 +1,338 @@
+// Package blacklist keeps a score of misbehaving peers by ip and by
+// identifier. Peers whose score reaches BanScore are banned for
+// BanDuration, scores go down again over time.
+package blacklist
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files"
+	"github.com/qqvv/go-nyzo/logging"
+	"github.com/qqvv/go-nyzo/message"
+)
+
+const DefaultFile = "blacklist.json"
+
+type Offense int
+
+const (
+	InvalidSignature Offense = iota + 1
+	MalformedMessage
+	Spam
+)
+
+func (o Offense) String() string {
+	switch o {
+	case InvalidSignature:
+		return "invalid signature"
+	case MalformedMessage:
+		return "malformed message"
+	case Spam:
+		return "spam"
+	}
+	return fmt.Sprintf("Offense(%d)", int(o))
+}
+
+var (
+	// Penalties are added to the score of a peer for each offense
+	Penalties = map[Offense]float64{
+		InvalidSignature: 25,
+		MalformedMessage: 10,
+		Spam:             50,
+	}
+	BanScore    = 100.0
+	BanDuration = 10 * time.Minute
+	// ScoreDecay is how much a score goes down per minute
+	ScoreDecay = 10.0
+
+	// SpamLimit is the number of messages an ip may send per SpamWindow
+	SpamLimit  = 1000
+	SpamWindow = 10 * time.Second
+)
+
+var log = logging.Logger("blacklist")
+
+type entry struct {
+	Score float64 `json:"score"`
+	// Updated is when the score was last changed, in nano
+	Updated int64 `json:"updated"`
+	// BannedUntil is in nano, 0 if never banned
+	BannedUntil int64 `json:"bannedUntil,omitempty"`
+}
+
+// add adds the penalty of o at now and returns true if this bans the peer
+func (e *entry) add(o Offense, now int64) bool {
+	e.Score = e.score(now) + Penalties[o]
+	e.Updated = now
+	if e.Score < BanScore || e.BannedUntil > now {
+		return false
+	}
+	// the score starts over after the ban
+	e.Score = 0
+	e.BannedUntil = now + int64(BanDuration)
+	return true
+}
+
+// expired is true once the entry neither bans nor counts anymore
+func (e *entry) expired(now int64) bool {
+	return e.BannedUntil <= now && e.score(now) == 0
+}
+
+// score is the score decayed until now
+func (e *entry) score(now int64) float64 {
+	s := e.Score - float64(now-e.Updated)/float64(time.Minute)*ScoreDecay
+	if s < 0 {
+		return 0
+	}
+	return s
+}
+
+// List is safe for concurrent use
+type List struct {
+	// Clock is the time of scores and bans, the system clock unless set
+	Clock clock.Clock
+
+	filename string
+
+	mu  sync.Mutex
+	ips map[string]*entry
+	ids map[crypto.PublicKey]*entry
+	// messages per ip in the current spam window
+	window   int64
+	messages map[string]int
+}
+
+type listFile struct {
+	IPs map[string]*entry `json:"ips"`
+	IDs map[string]*entry `json:"ids"`
+}
+
+// Load reads the list from filename in the data directory, a missing file
+// gives an empty list
+func Load(filename string) (*List, error) {
+	l := &List{
+		Clock:    clock.System{},
+		filename: filename,
+		ips:      make(map[string]*entry),
+		ids:      make(map[crypto.PublicKey]*entry),
+		messages: make(map[string]int),
+	}
+
+	b, err := files.ReadBytes(filename)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+	f := &listFile{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("invalid %v: %v", filename, err)
+	}
+	for ip, e := range f.IPs {
+		l.ips[ip] = e
+	}
+	for s, e := range f.IDs {
+		id, err := crypto.ParsePublicKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v: %v", filename, err)
+		}
+		l.ids[id] = e
+	}
+	return l, nil
+}
+
+// Save writes the entries that still matter to the file
+func (l *List) Save() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.save()
+}
+
+// save must be called with mu held
+func (l *List) save() error {
+	l.prune(l.Clock.Now().UnixNano())
+	f := &listFile{IPs: make(map[string]*entry), IDs: make(map[string]*entry)}
+	for ip, e := range l.ips {
+		f.IPs[ip] = e
+	}
+	for id, e := range l.ids {
+		f.IDs[id.String()] = e
+	}
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return files.Write(l.filename, b)
+}
+
+// Report adds the penalty of o to the scores of ip and id, either may be
+// empty. It returns true if this banned one of them.
+func (l *List) Report(ip string, id crypto.PublicKey, o Offense) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Clock.Now().UnixNano()
+	banned := false
+	if ip != "" {
+		e := l.ips[ip]
+		if e == nil {
+			e = &entry{}
+			l.ips[ip] = e
+		}
+		banned = e.add(o, now)
+	}
+	if id != (crypto.PublicKey{}) {
+		e := l.ids[id]
+		if e == nil {
+			e = &entry{}
+			l.ids[id] = e
+		}
+		banned = e.add(o, now) || banned
+	}
+	if banned {
+		args := []interface{}{"offense", o, "duration", BanDuration}
+		if ip != "" {
+			args = append(args, "ip", ip)
+		}
+		if id != (crypto.PublicKey{}) {
+			args = append(args, "id", id.StringCompact())
+		}
+		log.Info("banned peer", args...)
+		if err := l.save(); err != nil {
+			log.Error("saving blacklist", "err", err)
+		}
+	}
+	return banned
+}
+
+// Received counts a message from ip and reports it for spam once it sends
+// more than SpamLimit per SpamWindow. It returns false if the message
+// should be dropped.
+func (l *List) Received(ip string) bool {
+	l.mu.Lock()
+	now := l.Clock.Now().UnixNano()
+	window := now / int64(SpamWindow)
+	if window != l.window {
+		l.window = window
+		l.messages = make(map[string]int)
+		l.prune(now)
+	}
+	l.messages[ip]++
+	spam := l.messages[ip] > SpamLimit
+	l.mu.Unlock()
+
+	if spam {
+		l.Report(ip, crypto.PublicKey{}, Spam)
+		return false
+	}
+	return !l.BannedIP(ip)
+}
+
+func (l *List) BannedIP(ip string) bool {
+	_, banned := l.IPStatus(ip)
+	return banned
+}
+
+func (l *List) BannedID(id crypto.PublicKey) bool {
+	_, banned := l.IDStatus(id)
+	return banned
+}
+
+// IPStatus returns the score of ip and whether it is banned
+func (l *List) IPStatus(ip string) (Status, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Clock.Now().UnixNano()
+	if e := l.ips[ip]; e != nil && e.expired(now) {
+		delete(l.ips, ip)
+	}
+	return status(l.ips[ip], now)
+}
+
+func (l *List) IDStatus(id crypto.PublicKey) (Status, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.Clock.Now().UnixNano()
+	if e := l.ids[id]; e != nil && e.expired(now) {
+		delete(l.ids, id)
+	}
+	return status(l.ids[id], now)
+}
+
+// prune drops the expired entries so peers that stopped misbehaving don't
+// take up memory, it must be called with mu held
+func (l *List) prune(now int64) {
+	for ip, e := range l.ips {
+		if e.expired(now) {
+			delete(l.ips, ip)
+		}
+	}
+	for id, e := range l.ids {
+		if e.expired(now) {
+			delete(l.ids, id)
+		}
+	}
+}
+
+type Status struct {
+	Score float64
+	// BannedUntil is in nano, zero if not banned
+	BannedUntil int64
+}
+
+func status(e *entry, now int64) (Status, bool) {
+	if e == nil {
+		return Status{}, false
+	}
+	s := Status{Score: e.score(now)}
+	if e.BannedUntil > now {
+		s.BannedUntil = e.BannedUntil
+	}
+	return s, s.BannedUntil != 0
+}
+
+// HandleBlacklistStatusRequest tells a node its own status, by the ip it
+// connected from and its identifier
+func (l *List) HandleBlacklistStatusRequest(msg *message.Msg, addr net.Addr) *message.Msg {
+	ip := IP(addr)
+	ipStatus, ipBanned := l.IPStatus(ip)
+	idStatus, idBanned := l.IDStatus(msg.ID)
+
+	content := &message.BlacklistStatusResponseContent{Banned: ipBanned || idBanned}
+	content.BannedUntil = ipStatus.BannedUntil
+	if idStatus.BannedUntil > content.BannedUntil {
+		content.BannedUntil = idStatus.BannedUntil
+	}
+	content.Message = fmt.Sprintf("ip %v score %.0f, id %v score %.0f",
+		ip, ipStatus.Score, msg.ID.StringCompact(), idStatus.Score)
+	if content.Banned {
+		content.Message += ", banned until " + time.Unix(0, content.BannedUntil).UTC().Format(time.RFC3339)
+	}
+
+	resp := message.NewWithClock(l.Clock, int(message.BlacklistStatusResponse))
+	resp.Content = content.Serialize()
+	return resp
+}
+
+// IP is the host of addr without the port
+func IP(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
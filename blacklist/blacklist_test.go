@@ -0,0 +1,158 @@
+package blacklist
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files/filestest"
+	"github.com/qqvv/go-nyzo/message"
+)
+
+func newTestClock() *clock.Manual {
+	return clock.NewManual(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+// load loads the default file with c as the clock
+func load(t *testing.T, c clock.Clock) *List {
+	l, err := Load(DefaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Clock = c
+	return l
+}
+
+func TestBans(t *testing.T) {
+	filestest.SetDataDir(t)
+	c := newTestClock()
+	l := load(t, c)
+	id := crypto.GenPrivKey().PubKey()
+
+	// 25 per invalid signature, minus 10 per minute
+	tests := []struct {
+		wait    time.Duration
+		offense Offense
+		banned  bool
+		score   float64
+	}{
+		{0, InvalidSignature, false, 25},
+		{0, InvalidSignature, false, 50},
+		{time.Minute, InvalidSignature, false, 65},
+		{0, InvalidSignature, false, 90},
+		{0, MalformedMessage, true, 0},
+		// banned already
+		{time.Minute, Spam, false, 50},
+	}
+
+	for i, test := range tests {
+		c.Add(test.wait)
+		if banned := l.Report("10.0.0.1", id, test.offense); banned != test.banned {
+			t.Errorf("expected banned %v (%v)", test.banned, i)
+		}
+		status, _ := l.IPStatus("10.0.0.1")
+		if status.Score != test.score {
+			t.Errorf("expected score %v, got %v (%v)", test.score, status.Score, i)
+		}
+	}
+
+	if !l.BannedIP("10.0.0.1") || !l.BannedID(id) || l.BannedIP("10.0.0.2") {
+		t.Error("unexpected bans")
+	}
+
+	// bans survive a restart
+	l = load(t, c)
+	if !l.BannedIP("10.0.0.1") || !l.BannedID(id) {
+		t.Error("expected the bans to be loaded")
+	}
+
+	c.Add(BanDuration)
+	if l.BannedIP("10.0.0.1") || l.BannedID(id) {
+		t.Error("expected the bans to expire")
+	}
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+	l = load(t, c)
+	if len(l.ips) != 0 || len(l.ids) != 0 {
+		t.Errorf("expected expired entries to be dropped, got %v %v", l.ips, l.ids)
+	}
+}
+
+func TestSpam(t *testing.T) {
+	filestest.SetDataDir(t)
+	c := newTestClock()
+	l := load(t, c)
+	defer func(limit int) { SpamLimit = limit }(SpamLimit)
+	SpamLimit = 10
+
+	for i := 0; i < 10; i++ {
+		if !l.Received("10.0.0.1") {
+			t.Fatalf("message %v dropped", i)
+		}
+	}
+	// the count starts over with the next window
+	c.Add(SpamWindow)
+	for i := 0; i < 10; i++ {
+		l.Received("10.0.0.1")
+	}
+	if l.Received("10.0.0.1") || l.Received("10.0.0.1") {
+		t.Error("expected spam to be dropped")
+	}
+	if !l.BannedIP("10.0.0.1") || l.BannedIP("10.0.0.2") {
+		t.Error("expected the spamming ip to be banned")
+	}
+}
+
+func TestBlacklistStatus(t *testing.T) {
+	filestest.SetDataDir(t)
+	c := newTestClock()
+	l := load(t, c)
+	privKey := crypto.GenPrivKey()
+	for i := 0; i < 4; i++ {
+		l.Report("", privKey.PubKey(), InvalidSignature)
+	}
+
+	req := message.New(int(message.BlacklistStatusRequest))
+	req.Sign(privKey)
+	resp := l.HandleBlacklistStatusRequest(req, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 9444})
+	content := &message.BlacklistStatusResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		t.Fatal(err)
+	}
+	expected := c.Now().Add(BanDuration).UnixNano()
+	if !content.Banned || content.BannedUntil != expected {
+		t.Errorf("unexpected status %+v", content)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	filestest.SetDataDir(t)
+	c := newTestClock()
+	l := load(t, c)
+
+	for i := 0; i < 100; i++ {
+		l.Report(fmt.Sprintf("10.0.1.%d", i), crypto.PublicKey{byte(i + 1)}, MalformedMessage)
+	}
+	// banned with a score of 0
+	l.Report("10.0.0.1", crypto.PublicKey{}, Spam)
+	l.Report("10.0.0.1", crypto.PublicKey{}, Spam)
+	if len(l.ips) != 101 || len(l.ids) != 100 {
+		t.Fatalf("unexpected entries %v %v", len(l.ips), len(l.ids))
+	}
+
+	// a score of 10 is gone after a minute, the ban stays
+	c.Add(time.Minute)
+	l.Received("10.0.0.2")
+	if len(l.ips) != 1 || len(l.ids) != 0 {
+		t.Errorf("expected only the banned ip to be left, got %v %v", len(l.ips), len(l.ids))
+	}
+
+	c.Add(BanDuration)
+	if l.BannedIP("10.0.0.1") || len(l.ips) != 0 {
+		t.Errorf("expected the expired ban to be dropped, got %v", l.ips)
+	}
+}
@@ -3,15 +3,28 @@ package block
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"math"
 
 	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/clock"
 	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/logging"
 	"github.com/qqvv/go-nyzo/transaction"
-
-	"github.com/davecgh/go-spew/spew"
 )
 
+var log = logging.Logger("block")
+
+// maxTimestamp is the largest timestamp in milli that fits in nano
+const maxTimestamp = math.MaxInt64 / 1000 / 1000
+
+// MaxTxs limits the tx count DeserializeBlock accepts, the count is checked
+// before anything is allocated
+var MaxTxs = 20000
+
+var ErrTooManyTxs = errors.New("too many txes in block")
+
 type Block struct {
 	Height                int64             `json:"height"`
 	PrevBlockHash         crypto.Hash       `json:"prevBlockHash"`
@@ -63,22 +76,78 @@ func (bl *Block) Deserialize(i interface{}) error {
 	}
 
 	blockCount := int16(0)
-	binary.Read(buf, binary.BigEndian, &blockCount)
+	if err := binary.Read(buf, binary.BigEndian, &blockCount); err != nil {
+		return fmt.Errorf("cannot read block count: %v", err)
+	}
 
 	// TODO: handle multiple blocks
 	if blockCount != 1 {
 		return fmt.Errorf("cannot deserialize %v blocks", blockCount)
 	}
 
+	if err := bl.DeserializeBlock(buf); err != nil {
+		return err
+	}
+
+	balList := &balancelist.List{}
+	if err := balList.Deserialize(buf); err != nil {
+		return err
+	}
+	log.Debug("deserialized block",
+		"height", bl.Height,
+		"verifier", bl.VerifierID.StringCompact(),
+		"txs", len(bl.Transactions),
+		"balanceListHeight", balList.Height,
+		"balanceListItems", len(balList.Items),
+		"rolloverFees", balList.RolloverFees)
+
+	return nil
+}
+
+// DeserializeBlock reads a single block as written by Serialize, without
+// the block count and balance list that Deserialize expects
+func (bl *Block) DeserializeBlock(i interface{}) error {
+	var buf *bytes.Buffer
+
+	switch i.(type) {
+	case *bytes.Buffer:
+		buf = i.(*bytes.Buffer)
+	case []byte:
+		buf = bytes.NewBuffer(i.([]byte))
+	default:
+		return fmt.Errorf("cannot deserialize block from %#v", i)
+	}
+
+	// height (8) + prevhash (32) + timestamps (16) + tx count (4)
+	if buf.Len() < 60 {
+		return fmt.Errorf("block is too short")
+	}
 	binary.Read(buf, binary.BigEndian, &bl.Height)
 	binary.Read(buf, binary.BigEndian, &bl.PrevBlockHash)
 	binary.Read(buf, binary.BigEndian, &bl.StartTimestamp)
 	binary.Read(buf, binary.BigEndian, &bl.VerificationTimestamp)
+	for _, ts := range []int64{bl.StartTimestamp, bl.VerificationTimestamp} {
+		if ts > maxTimestamp || ts < -maxTimestamp {
+			return fmt.Errorf("block timestamp out of range: %v", ts)
+		}
+	}
 	bl.StartTimestamp *= 1000 * 1000        // to nano
 	bl.VerificationTimestamp *= 1000 * 1000 // to nano
 
-	txCount := int(binary.BigEndian.Uint32(buf.Next(4)))
-	for i := 0; i < txCount; i++ {
+	var txCount uint32
+	binary.Read(buf, binary.BigEndian, &txCount)
+	if txCount > uint32(MaxTxs) {
+		return fmt.Errorf("%w: %v, max %v", ErrTooManyTxs, txCount, MaxTxs)
+	}
+	// every tx is at least 49 bytes and the block ends with 128 more
+	if int64(buf.Len()) < int64(txCount)*49+128 {
+		return fmt.Errorf("block has %v txes but only %v bytes are left", txCount, buf.Len())
+	}
+
+	if txCount > 0 {
+		bl.Transactions = make([]*transaction.Tx, 0, txCount)
+	}
+	for i := 0; i < int(txCount); i++ {
 		tx := &transaction.Tx{}
 		if err := tx.Deserialize(buf); err != nil {
 			return fmt.Errorf("error deserializing Tx from block: %v", err)
@@ -86,14 +155,14 @@ func (bl *Block) Deserialize(i interface{}) error {
 		bl.Transactions = append(bl.Transactions, tx)
 	}
 
+	// balancelist hash (32) + verifier pubk (32) + verifier sig (64)
+	if buf.Len() < 128 {
+		return fmt.Errorf("block is too short")
+	}
 	binary.Read(buf, binary.BigEndian, &bl.BalancelistHash)
 	binary.Read(buf, binary.BigEndian, &bl.VerifierID)
 	binary.Read(buf, binary.BigEndian, &bl.VerifierSig)
 
-	balList := &balancelist.List{}
-	balList.Deserialize(buf)
-	spew.Dump(balList)
-
 	return nil
 }
 
@@ -113,6 +182,25 @@ func (bl *Block) Sign(privKey crypto.PrivateKey) {
 	bl.VerifierSig = privKey.Sign(bl.ForSigning())
 }
 
+// Validate checks the verifier signature and the txes, the tx signatures
+// are verified in parallel
+func (bl *Block) Validate() error {
+	if !bl.VerifierID.Verify(bl.ForSigning(), bl.VerifierSig) {
+		return fmt.Errorf("block %v signature is not valid", bl.Height)
+	}
+
+	// the genesis block has the coingeneration tx, which is not valid later
+	if bl.Height == 0 {
+		return nil
+	}
+	for i, err := range transaction.ValidateAll(bl.Transactions) {
+		if err != nil {
+			return fmt.Errorf("invalid tx %v in block %v: %v", i, bl.Height, err)
+		}
+	}
+	return nil
+}
+
 func (bl *Block) Hash() crypto.Hash {
 	return crypto.DoubleSHA256(bl.VerifierSig[:])
 }
@@ -121,11 +209,19 @@ func (bl *Block) PrevHeight() int64 {
 	return bl.Height - 1
 }
 
+// New is NewWithClock with the system clock
 func New(height, startTimestamp int64, prevBlockHash, balancelistHash crypto.Hash) *Block {
+	return NewWithClock(clock.System{}, height, startTimestamp, prevBlockHash, balancelistHash)
+}
+
+// NewWithClock sets the verification timestamp to the time of c, which
+// should be the network time for blocks that are sent out
+func NewWithClock(c clock.Clock, height, startTimestamp int64, prevBlockHash, balancelistHash crypto.Hash) *Block {
 	bl := &Block{}
 	bl.Height = height
 	bl.PrevBlockHash = prevBlockHash
 	bl.StartTimestamp = startTimestamp
+	bl.VerificationTimestamp = c.Now().UnixNano()
 	bl.BalancelistHash = balancelistHash
 	return bl
 }
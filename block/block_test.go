@@ -0,0 +1,177 @@
+package block
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+func TestBlockJSON(t *testing.T) {
+	privKey := crypto.GenPrivKey()
+	tx := transaction.NewStandard(1, crypto.PublicKey{0x01}, []byte("data"))
+	tx.Sign(privKey)
+
+	tests := []*Block{
+		New(0, 0, crypto.Hash{}, crypto.Hash{}),
+		New(1, 1000, crypto.DoubleSHA256([]byte("prev")), crypto.DoubleSHA256([]byte("bl"))),
+		New(2, 2000, crypto.Hash{}, crypto.Hash{}),
+	}
+	tests[2].Transactions = []*transaction.Tx{tx}
+
+	for i, bl := range tests {
+		bl.Sign(privKey)
+
+		b, err := json.Marshal(bl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newBl := &Block{}
+		if err := json.Unmarshal(b, newBl); err != nil {
+			t.Errorf("unmarshalling failed (%v): %v", i, err)
+		}
+		if !reflect.DeepEqual(bl, newBl) {
+			t.Errorf("JSON round trip does not match (%v): %+v != %+v", i, bl, newBl)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	privKey := crypto.GenPrivKey()
+	newTx := func() *transaction.Tx {
+		tx := transaction.NewStandard(1, crypto.PublicKey{0x01}, nil)
+		tx.Sign(crypto.GenPrivKey())
+		return tx
+	}
+
+	valid := New(1, 0, crypto.Hash{}, crypto.Hash{})
+	valid.Transactions = []*transaction.Tx{newTx(), newTx(), newTx()}
+	valid.Sign(privKey)
+
+	badTxSig := New(1, 0, crypto.Hash{}, crypto.Hash{})
+	badTxSig.Transactions = []*transaction.Tx{newTx(), newTx()}
+	badTxSig.Transactions[1].SenderSig[0] ^= 0xff
+	badTxSig.Sign(privKey)
+
+	badSig := New(1, 0, crypto.Hash{}, crypto.Hash{})
+	badSig.Sign(privKey)
+	badSig.Height = 2
+
+	genesis := New(0, 0, crypto.Hash{}, crypto.Hash{})
+	genesis.Transactions = []*transaction.Tx{{Type: 0, Amount: 1}}
+	genesis.Sign(privKey)
+
+	tests := []struct {
+		bl    *Block
+		valid bool
+	}{
+		{valid, true},
+		{badTxSig, false},
+		{badSig, false},
+		{genesis, true},
+	}
+
+	for i, test := range tests {
+		err := test.bl.Validate()
+		if test.valid && err != nil {
+			t.Errorf("expected block to be valid (%v): %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected block to be invalid (%v)", i)
+		}
+	}
+}
+
+func FuzzBlockDeserialize(f *testing.F) {
+	privKey := crypto.GenPrivKey()
+	tx := transaction.NewStandard(1, crypto.PublicKey{0x01}, []byte("data"))
+	tx.Sign(privKey)
+
+	empty := New(1, 1000*1000*1000, crypto.Hash{}, crypto.Hash{})
+	empty.Sign(privKey)
+	withTxs := New(2, 2000*1000*1000, empty.Hash(), crypto.Hash{})
+	withTxs.Transactions = []*transaction.Tx{tx, tx}
+	withTxs.Sign(privKey)
+
+	for _, bl := range []*Block{empty, withTxs} {
+		b := bl.Serialize()
+		f.Add(b)
+		f.Add(b[:len(b)-1])
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// with the block count and balancelist only panics are checked
+		(&Block{}).Deserialize(append([]byte{0x00, 0x01}, data...))
+
+		buf := bytes.NewBuffer(data)
+		bl := &Block{}
+		if err := bl.DeserializeBlock(buf); err != nil {
+			return
+		}
+		// the smallest tx is a coingeneration tx of 49 bytes
+		if len(bl.Transactions) > len(data)/49 {
+			t.Fatalf("%v txes from %v bytes", len(bl.Transactions), len(data))
+		}
+
+		expected := append([]byte{}, data[:len(data)-buf.Len()]...)
+		// a sender data length over 32 is read as 32
+		offset := 60
+		for _, tx := range bl.Transactions {
+			if tx.Type != 0 && expected[offset+89] > transaction.MaxSenderDataLen {
+				expected[offset+89] = transaction.MaxSenderDataLen
+			}
+			offset += tx.SerializedLen()
+		}
+		if b := bl.Serialize(); !bytes.Equal(b, expected) {
+			t.Fatalf("re-encoded block differs\nexpected %x\ngot      %x", expected, b)
+		}
+	})
+}
+
+func TestDeserializeLimits(t *testing.T) {
+	prevMaxTxs := MaxTxs
+	defer func() { MaxTxs = prevMaxTxs }()
+	MaxTxs = 2
+
+	coinGen := &transaction.Tx{Type: 0, Amount: 1}
+	block := func(txCount uint32, txs int) []byte {
+		buf := bytes.NewBuffer(make([]byte, 56)) // height, prevhash and timestamps
+		binary.Write(buf, binary.BigEndian, txCount)
+		for i := 0; i < txs; i++ {
+			buf.Write(coinGen.Serialize())
+		}
+		buf.Write(make([]byte, 128))
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		data    []byte
+		valid   bool
+		tooMany bool
+	}{
+		{block(2, 2), true, false},
+		{block(0, 0), true, false},
+		{block(3, 3), false, true},
+		{block(0xffffffff, 0), false, true},
+		{block(2, 1), false, false},
+	}
+
+	for i, test := range tests {
+		err := (&Block{}).DeserializeBlock(test.data)
+		if test.valid && err != nil {
+			t.Errorf("expected block to be valid (%v): %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected block to be invalid (%v)", i)
+		}
+		if errors.Is(err, ErrTooManyTxs) != test.tooMany {
+			t.Errorf("unexpected error (%v): %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package clock is the time source of everything that creates timestamps.
+// Constructors and components take a Clock and use System unless told
+// otherwise, a node passes its Median clock to follow the time of the
+// network and tests pass a Manual one.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the local system clock
+type System struct{}
+
+func (System) Now() time.Time { return time.Now() }
+
+// Manual only moves when told to
+type Manual struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func NewManual(t time.Time) *Manual {
+	return &Manual{t: t}
+}
+
+func (m *Manual) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.t
+}
+
+func (m *Manual) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.t = t
+}
+
+func (m *Manual) Add(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.t = m.t.Add(d)
+}
@@ -0,0 +1,93 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestManual(t *testing.T) {
+	m := NewManual(epoch)
+
+	if !m.Now().Equal(epoch) {
+		t.Errorf("unexpected time %v", m.Now())
+	}
+	m.Add(time.Second)
+	if !m.Now().Equal(epoch.Add(time.Second)) {
+		t.Errorf("unexpected time %v", m.Now())
+	}
+	m.Set(epoch)
+	if !m.Now().Equal(epoch) {
+		t.Errorf("unexpected time %v", m.Now())
+	}
+}
+
+func TestMedian(t *testing.T) {
+	base := NewManual(epoch)
+	m := NewMedian(base)
+
+	// the peer time is compared with the middle of the round trip
+	add := func(peer string, offset time.Duration) {
+		sent := base.Now()
+		m.Add(peer, sent.Add(50*time.Millisecond+offset), sent, sent.Add(100*time.Millisecond))
+	}
+
+	tests := []struct {
+		peer     string
+		offset   time.Duration
+		expected time.Duration
+	}{
+		// not enough peers yet
+		{"a", time.Second, 0},
+		{"b", 3 * time.Second, 0},
+		// an outlier, ignored
+		{"c", -time.Hour, 2 * time.Second},
+		{"d", 2 * time.Second, 2 * time.Second},
+		// a peer counts once
+		{"d", 4 * time.Second, 3 * time.Second},
+		{"e", time.Hour, 3 * time.Second},
+	}
+
+	for i, test := range tests {
+		add(test.peer, test.offset)
+		if o := m.Offset(); o != test.expected {
+			t.Errorf("expected offset %v, got %v (%v)", test.expected, o, i)
+		}
+	}
+
+	if !m.Now().Equal(epoch.Add(3 * time.Second)) {
+		t.Errorf("unexpected time %v", m.Now())
+	}
+	m.Remove("e")
+	if m.Peers() != 4 || m.Offset() != 3*time.Second {
+		t.Errorf("unexpected offset %v of %v peers", m.Offset(), m.Peers())
+	}
+}
+
+func TestWithoutOutliers(t *testing.T) {
+	ms := func(ms ...int) []time.Duration {
+		d := make([]time.Duration, len(ms))
+		for i := range ms {
+			d[i] = time.Duration(ms[i]) * time.Millisecond
+		}
+		return d
+	}
+
+	tests := []struct {
+		offsets  []time.Duration
+		expected int
+	}{
+		{ms(0), 1},
+		{ms(0, 0, 0, 50), 4},
+		{ms(0, 0, 0, 500), 3},
+		{ms(100, 200, 300, 400, 5000), 4},
+		{ms(-9000, 100, 200, 300, 9000), 3},
+	}
+
+	for i, test := range tests {
+		if kept := withoutOutliers(test.offsets); len(kept) != test.expected {
+			t.Errorf("expected %v offsets kept, got %v (%v)", test.expected, kept, i)
+		}
+	}
+}
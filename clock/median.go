@@ -0,0 +1,151 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultMinPeers is the number of peers a Median clock needs before it
+// moves away from its base clock
+const DefaultMinPeers = 3
+
+var (
+	// OutlierMADs is how many median absolute deviations from the median
+	// a peer may be before it is ignored
+	OutlierMADs = 3
+	// MinOutlierDistance keeps peers close to the median even if all
+	// others agree exactly
+	MinOutlierDistance = 100 * time.Millisecond
+)
+
+// Median is a base clock adjusted by the median of the offsets of the
+// peers' clocks, as reported in their TimestampResponses. Peers far from
+// the others are ignored, so it needs no NTP, only a majority of honest
+// peers.
+type Median struct {
+	Base     Clock
+	MinPeers int
+
+	mu      sync.RWMutex
+	offsets map[string]time.Duration
+	offset  time.Duration
+}
+
+func NewMedian(base Clock) *Median {
+	return &Median{
+		Base:     base,
+		MinPeers: DefaultMinPeers,
+		offsets:  make(map[string]time.Duration),
+	}
+}
+
+func (m *Median) Now() time.Time {
+	return m.Base.Now().Add(m.Offset())
+}
+
+// Offset is how far the base clock is behind the peers
+func (m *Median) Offset() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.offset
+}
+
+// Add records the timestamp of peer, taken at the middle of a request sent
+// and its response received at the given times of the base clock. A peer
+// only counts once, with its latest timestamp.
+func (m *Median) Add(peer string, peerTime, sent, received time.Time) {
+	local := sent.Add(received.Sub(sent) / 2)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.offsets[peer] = peerTime.Sub(local)
+	m.update()
+}
+
+func (m *Median) Remove(peer string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.offsets, peer)
+	m.update()
+}
+
+// Retain removes all peers but the given ones
+func (m *Median) Retain(peers []string) {
+	keep := make(map[string]bool, len(peers))
+	for _, peer := range peers {
+		keep[peer] = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for peer := range m.offsets {
+		if !keep[peer] {
+			delete(m.offsets, peer)
+		}
+	}
+	m.update()
+}
+
+func (m *Median) Peers() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return len(m.offsets)
+}
+
+// update must be called with mu held
+func (m *Median) update() {
+	if len(m.offsets) < m.MinPeers || len(m.offsets) == 0 {
+		m.offset = 0
+		return
+	}
+	offsets := make([]time.Duration, 0, len(m.offsets))
+	for _, o := range m.offsets {
+		offsets = append(offsets, o)
+	}
+	m.offset = median(withoutOutliers(offsets))
+}
+
+// withoutOutliers drops the offsets more than OutlierMADs median absolute
+// deviations from the median, but never within MinOutlierDistance of it
+func withoutOutliers(offsets []time.Duration) []time.Duration {
+	mid := median(offsets)
+	deviations := make([]time.Duration, len(offsets))
+	for i, o := range offsets {
+		deviations[i] = abs(o - mid)
+	}
+	limit := median(deviations) * time.Duration(OutlierMADs)
+	if limit < MinOutlierDistance {
+		limit = MinOutlierDistance
+	}
+
+	var kept []time.Duration
+	for _, o := range offsets {
+		if abs(o-mid) <= limit {
+			kept = append(kept, o)
+		}
+	}
+	return kept
+}
+
+// median sorts offsets, for an even count it is the mean of the middle two
+func median(offsets []time.Duration) time.Duration {
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	n := len(offsets)
+	if n%2 == 1 {
+		return offsets[n/2]
+	}
+	return (offsets[n/2-1] + offsets[n/2]) / 2
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
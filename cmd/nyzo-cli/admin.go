@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/qqvv/go-nyzo/admin"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+func adminRequest(args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ExitOnError)
+	node := fs.String("node", "", "node to send the request to (host[:port])")
+	key := fs.String("key", "", "seed of the node or an admin key (hex or key_ string)")
+	name := fs.String("name", "", "key name in the keystore (instead of -key)")
+	fs.Usage = func() {
+		names := []string{}
+		for name := range admin.Requests {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(os.Stderr, "usage: nyzo-cli admin -node <host[:port]> -key <seed> <request> [block hash]\n\nrequests:\n")
+		for _, name := range names {
+			fmt.Fprintf(os.Stderr, "  %v\n", name)
+		}
+		fmt.Fprintln(os.Stderr)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	msgType, ok := admin.Requests[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown request %q", fs.Arg(0))
+	}
+	if *node == "" {
+		return fmt.Errorf("a -node is required")
+	}
+	privKey, err := senderKey(*key, *name)
+	if err != nil {
+		return err
+	}
+
+	msg := message.New(int(msgType))
+	if msgType == message.BlockRejectionRequest {
+		if fs.NArg() != 2 {
+			return fmt.Errorf("a block hash is required")
+		}
+		h, err := crypto.ParseHash(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("invalid block hash: %v", err)
+		}
+		msg.Content = h[:]
+	}
+	msg.Sign(privKey)
+
+	resp, err := network.Fetch(network.WithDefaultPort(*node), msg)
+	if err != nil {
+		return err
+	}
+	if resp.Type != msgType+1 {
+		return fmt.Errorf("unexpected response type %v", resp.Type)
+	}
+	content := &message.MultilineTextResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		return err
+	}
+	for _, line := range content.Lines {
+		fmt.Println(line)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/qqvv/go-nyzo/conformance"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+// requests that can be captured and the response they get
+var captureRequests = map[string][2]message.MsgType{
+	"ping":      {message.Ping, message.PingResponse},
+	"prevhash":  {message.PrevHashRequest, message.PrevHashResponse},
+	"timestamp": {message.TimestampRequest, message.TimestampResponse},
+}
+
+// capture records the response of a node byte for byte as a conformance
+// vector, the node signs it so it is a capture of a signed message type
+func capture(args []string) error {
+	names := []string{}
+	for name := range captureRequests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fs := flag.NewFlagSet("capture", flag.ExitOnError)
+	out := fs.String("o", "", "write the vector to this file instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: nyzo-cli capture [-o file] <%v> <host[:port]>\n", names)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	types, ok := captureRequests[fs.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown request %v, expected one of %v", fs.Arg(0), names)
+	}
+	addr := network.WithDefaultPort(fs.Arg(1))
+
+	req := message.New(int(types[0]))
+	req.Sign(crypto.GenPrivKey())
+	raw, err := fetchRaw(addr, req)
+	if err != nil {
+		return err
+	}
+	resp, err := network.ReadMsg(bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	if resp.Type != types[1] {
+		return fmt.Errorf("unexpected response type %v", resp.Type)
+	}
+
+	// a node always signs its responses, the check must agree
+	sigValid := true
+	v := &conformance.Vector{
+		Description: fmt.Sprintf("%v to a %v", resp.Type, req.Type),
+		Source:      fmt.Sprintf("node %v on %v", addr, time.Now().UTC().Format("2006-01-02")),
+		Kind:        "message",
+		Hex:         hex.EncodeToString(raw),
+		SigValid:    &sigValid,
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		fmt.Println(string(b))
+		return nil
+	}
+	return ioutil.WriteFile(*out, append(b, '\n'), 0644)
+}
+
+// fetchRaw is network.Fetch without decoding, it returns the response with
+// its length prefix as it was received
+func fetchRaw(addr string, msg *message.Msg) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", addr, network.DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(network.DefaultTimeout))
+
+	if _, err := conn.Write(msg.Serialize()); err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 4)
+	if _, err := io.ReadFull(conn, raw); err != nil {
+		return nil, err
+	}
+	msgLen := int32(binary.BigEndian.Uint32(raw))
+	if msgLen < 4 || msgLen > network.MaxMsgLen {
+		return nil, fmt.Errorf("%w: invalid length %v", network.ErrMalformed, msgLen)
+	}
+	raw = append(raw, make([]byte, msgLen-4)...)
+	if _, err := io.ReadFull(conn, raw[4:]); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
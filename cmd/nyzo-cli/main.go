@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files"
+	"github.com/qqvv/go-nyzo/keystore"
+)
+
+type command struct {
+	run   func(args []string) error
+	usage string
+}
+
+var commands = map[string]command{
+	"keygen":  {keygen, "generate a new key, optionally storing it in the keystore"},
+	"show":    {show, "show a seed or id in every format"},
+	"tx":      {createTx, "create and sign a standard transaction"},
+	"prepare": {prepareTx, "create an unsigned transaction for offline signing"},
+	"sign":    {signTx, "sign a prepared transaction offline"},
+	"submit":  {submitTx, "submit a signed transaction to a node"},
+	"admin":   {adminRequest, "send an admin request signed by the node or an admin key"},
+	"ping":    {ping, "measure the round trip to nodes and show their status"},
+	"capture": {capture, "record a node's response as a conformance vector"},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: nyzo-cli [-datadir dir] <command> [arguments]\n\ncommands:\n")
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-8v %v\n", name, commands[name].usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nrun nyzo-cli <command> -h for the arguments of a command\n")
+}
+
+func main() {
+	flag.Usage = usage
+	dataDir := flag.String("datadir", "", "data directory (default "+files.AppDataDir()+")")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command: %v\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	if *dataDir != "" {
+		if err := files.SetDataDir(*dataDir); err != nil {
+			fatal(err)
+		}
+	}
+
+	if err := cmd.run(flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	os.Exit(1)
+}
+
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	name := fs.String("name", "", "store the key in the keystore under this name")
+	fs.Parse(args)
+
+	if *name == "" {
+		printKey(crypto.GenPrivKey())
+		return nil
+	}
+
+	ks, err := openKeystore(true)
+	if err != nil {
+		return err
+	}
+	id, err := ks.Add(*name)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("stored key %v\n", *name)
+	printID(id)
+	return nil
+}
+
+func show(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: nyzo-cli show <seed or id>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	s := strings.TrimSpace(fs.Arg(0))
+
+	if strings.HasPrefix(s, string(crypto.PrefixPublicID)) {
+		id, err := crypto.ParseNyzoPublicKey(s)
+		if err != nil {
+			return err
+		}
+		printID(id)
+		return nil
+	}
+
+	// hex can be either a seed or an id, it's shown as both
+	if !strings.HasPrefix(s, string(crypto.PrefixPrivateSeed)) {
+		if id, err := crypto.ParsePublicKey(s); err == nil {
+			fmt.Println("as id:")
+			printID(id)
+			fmt.Println("\nas seed:")
+		}
+	}
+	privKey, err := crypto.ParseSeed(s)
+	if err != nil {
+		return err
+	}
+	printKey(privKey)
+	return nil
+}
+
+func printKey(privKey crypto.PrivateKey) {
+	fmt.Printf("seed:       %v\n", privKey.StringWithDashes())
+	fmt.Printf("seed (raw): %v\n", privKey.String())
+	fmt.Printf("seed nyzo:  %v\n", privKey.NyzoString())
+	printID(privKey.PubKey())
+}
+
+func printID(id crypto.PublicKey) {
+	fmt.Printf("id:         %v\n", id.StringWithDashes())
+	fmt.Printf("id (raw):   %v\n", id.String())
+	fmt.Printf("id nyzo:    %v\n", id.NyzoString())
+}
+
+// openKeystore opens and unlocks the keystore in the data directory. The
+// password is read from NYZO_KEYSTORE_PASSWORD or stdin.
+func openKeystore(create bool) (*keystore.Keystore, error) {
+	if !files.Exists(keystore.DefaultFile) {
+		if !create {
+			return nil, fmt.Errorf("no keystore in %v", files.DataDir())
+		}
+		password, err := readPassword("new keystore password: ")
+		if err != nil {
+			return nil, err
+		}
+		return keystore.Create(keystore.DefaultFile, password)
+	}
+
+	ks, err := keystore.Open(keystore.DefaultFile)
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword("keystore password: ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ks.Unlock(password); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+func readPassword(prompt string) (string, error) {
+	if password, ok := os.LookupEnv("NYZO_KEYSTORE_PASSWORD"); ok {
+		return password, nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("cannot read password: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
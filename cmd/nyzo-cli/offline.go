@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+// prepareTx is the online step of offline signing: it builds the unsigned
+// tx with a recent prev hash from a node and writes it to a file that is
+// carried to the signing machine
+func prepareTx(args []string) error {
+	fs := flag.NewFlagSet("prepare", flag.ExitOnError)
+	to := fs.String("to", "", "recipient id (hex or id__ string)")
+	amount := fs.String("amount", "", "amount in nyzos, e.g. 1.5")
+	data := fs.String("data", "", "sender data, text or X(hex) up to 32 bytes")
+	prevHeight := fs.Int64("prev-height", 0, "height of the block whose hash is signed")
+	prevHash := fs.String("prev-hash", "", "hash of the block at prev-height (hex), fetched from -node if empty")
+	node := fs.String("node", "", "node to fetch the prev hash from (host[:port])")
+	out := fs.String("out", "", "file to write the unsigned tx to (default stdout)")
+	fs.Parse(args)
+
+	recipientID, err := crypto.ParseID(*to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %v", err)
+	}
+	micros, err := parseAmount(*amount)
+	if err != nil {
+		return err
+	}
+
+	senderData, err := transaction.ParseSenderData(*data)
+	if err != nil {
+		return fmt.Errorf("invalid sender data: %v", err)
+	}
+	tx, err := transaction.NewStandardStrict(micros, recipientID, senderData)
+	if err != nil {
+		return err
+	}
+	if err := setPrevHash(tx, *prevHeight, *prevHash, *node); err != nil {
+		return err
+	}
+
+	b, err := tx.MarshalUnsigned()
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		fmt.Println(string(b))
+		return nil
+	}
+	return ioutil.WriteFile(*out, append(b, '\n'), 0644)
+}
+
+// signTx is the offline step: it needs no network access
+func signTx(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	in := fs.String("in", "", "unsigned tx file from prepare (default stdin)")
+	key := fs.String("key", "", "sender seed (hex or key_ string)")
+	name := fs.String("name", "", "sender key name in the keystore (instead of -key)")
+	format := fs.String("format", "nyzo", "output format: nyzo, hex or json")
+	fs.Parse(args)
+
+	var b []byte
+	var err error
+	if *in == "" {
+		b, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		b, err = ioutil.ReadFile(*in)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := transaction.ParseUnsigned(b)
+	if err != nil {
+		return err
+	}
+	privKey, err := senderKey(*key, *name)
+	if err != nil {
+		return err
+	}
+	tx.Sign(privKey)
+
+	if valid, err := tx.Validate(); !valid {
+		return err
+	}
+	return printTx(tx, *format)
+}
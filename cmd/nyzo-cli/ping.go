@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+type pingResult struct {
+	content *message.PingResponseContent
+	rtt     time.Duration
+	err     error
+}
+
+func ping(args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ExitOnError)
+	count := fs.Int("count", 1, "pings per node, the fastest round trip is shown")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: nyzo-cli ping [-count n] <host[:port]>...\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || *count < 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	// all nodes are pinged at once, each one count times in a row
+	results := make([]pingResult, fs.NArg())
+	var wg sync.WaitGroup
+	for i, node := range fs.Args() {
+		wg.Add(1)
+		go func(r *pingResult, addr string) {
+			defer wg.Done()
+			for j := 0; j < *count; j++ {
+				content, rtt, err := network.Ping(addr)
+				if err != nil {
+					r.err = err
+					continue
+				}
+				if r.content == nil || rtt < r.rtt {
+					r.rtt = rtt
+				}
+				r.content, r.err = content, nil
+			}
+		}(&results[i], network.WithDefaultPort(node))
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, r := range results {
+		node := fs.Arg(i)
+		if r.content == nil {
+			failed++
+			fmt.Printf("%v: %v\n", node, r.err)
+			continue
+		}
+		edge := "none"
+		if r.content.FrozenEdge >= 0 {
+			edge = fmt.Sprint(r.content.FrozenEdge)
+		}
+		fmt.Printf("%v: rtt %v, version %v, frozen edge %v, uptime %v\n", node,
+			r.rtt.Round(time.Microsecond), r.content.Version, edge,
+			time.Duration(r.content.Uptime).Round(time.Second))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v of %v nodes did not answer", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+const microNyzos = 1000000
+
+func createTx(args []string) error {
+	fs := flag.NewFlagSet("tx", flag.ExitOnError)
+	to := fs.String("to", "", "recipient id (hex or id__ string)")
+	amount := fs.String("amount", "", "amount in nyzos, e.g. 1.5")
+	data := fs.String("data", "", "sender data, text or X(hex) up to 32 bytes")
+	key := fs.String("key", "", "sender seed (hex or key_ string)")
+	name := fs.String("name", "", "sender key name in the keystore (instead of -key)")
+	prevHeight := fs.Int64("prev-height", 0, "height of the block whose hash is signed")
+	prevHash := fs.String("prev-hash", "", "hash of the block at prev-height (hex), fetched from -node if empty")
+	format := fs.String("format", "nyzo", "output format: nyzo, hex or json")
+	doSubmit := fs.Bool("submit", false, "submit the tx to -node")
+	node := fs.String("node", "", "node to fetch the prev hash from and submit to (host[:port])")
+	fs.Parse(args)
+
+	recipientID, err := crypto.ParseID(*to)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %v", err)
+	}
+	micros, err := parseAmount(*amount)
+	if err != nil {
+		return err
+	}
+
+	privKey, err := senderKey(*key, *name)
+	if err != nil {
+		return err
+	}
+
+	senderData, err := transaction.ParseSenderData(*data)
+	if err != nil {
+		return fmt.Errorf("invalid sender data: %v", err)
+	}
+	tx, err := transaction.NewStandardStrict(micros, recipientID, senderData)
+	if err != nil {
+		return err
+	}
+	if err := setPrevHash(tx, *prevHeight, *prevHash, *node); err != nil {
+		return err
+	}
+	tx.Sign(privKey)
+
+	if valid, err := tx.Validate(); !valid {
+		return err
+	}
+	if err := printTx(tx, *format); err != nil {
+		return err
+	}
+
+	if *doSubmit {
+		return submit(tx, *node)
+	}
+	return nil
+}
+
+// senderKey reads the key from a seed or from the keystore by name
+func senderKey(key, name string) (crypto.PrivateKey, error) {
+	switch {
+	case key != "" && name != "":
+		return crypto.PrivateKey{}, fmt.Errorf("only one of -key and -name can be given")
+	case key != "":
+		privKey, err := crypto.ParseSeed(key)
+		if err != nil {
+			return crypto.PrivateKey{}, fmt.Errorf("invalid key: %v", err)
+		}
+		return privKey, nil
+	case name != "":
+		ks, err := openKeystore(false)
+		if err != nil {
+			return crypto.PrivateKey{}, err
+		}
+		return ks.Export(name)
+	default:
+		return crypto.PrivateKey{}, fmt.Errorf("a sender -key or -name is required")
+	}
+}
+
+// setPrevHash uses the given prev hash or fetches a recent one from node
+func setPrevHash(tx *transaction.Tx, height int64, hash, node string) error {
+	if hash != "" {
+		h, err := crypto.ParseHash(hash)
+		if err != nil {
+			return fmt.Errorf("invalid prev hash: %v", err)
+		}
+		tx.SetPrevHash(height, h)
+		return nil
+	}
+	if node == "" {
+		return fmt.Errorf("a -prev-hash or a -node to fetch it from is required")
+	}
+
+	height, h, err := network.FetchPrevHash(network.WithDefaultPort(node))
+	if err != nil {
+		return fmt.Errorf("cannot fetch prev hash: %v", err)
+	}
+	tx.SetPrevHash(height, h)
+	return nil
+}
+
+func submitTx(args []string) error {
+	fs := flag.NewFlagSet("submit", flag.ExitOnError)
+	node := fs.String("node", "", "node to submit to (host[:port])")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: nyzo-cli submit -node <host[:port]> <tx (hex or tx__ string)>\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	tx, err := parseTx(strings.TrimSpace(fs.Arg(0)))
+	if err != nil {
+		return err
+	}
+	if valid, err := tx.Validate(); !valid {
+		return err
+	}
+	return submit(tx, *node)
+}
+
+func submit(tx *transaction.Tx, node string) error {
+	if node == "" {
+		return fmt.Errorf("a -node is required")
+	}
+	msg := message.New(int(message.Transaction))
+	msg.Content = tx.Serialize()
+	// the message itself can be signed by anyone
+	msg.Sign(crypto.GenPrivKey())
+
+	resp, err := network.Fetch(network.WithDefaultPort(node), msg)
+	if err != nil {
+		return err
+	}
+	if resp.Type != message.TransactionResponse {
+		return fmt.Errorf("unexpected response type %v", resp.Type)
+	}
+
+	content := &message.TransactionResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		return err
+	}
+	fmt.Printf("accepted: %v\nmessage:  %v\n", content.Accepted, content.Message)
+	if !content.Accepted {
+		return fmt.Errorf("tx was not accepted")
+	}
+	return nil
+}
+
+func printTx(tx *transaction.Tx, format string) error {
+	switch format {
+	case "nyzo":
+		fmt.Println(tx.NyzoString())
+	case "hex":
+		fmt.Println(hex.EncodeToString(tx.Serialize()))
+	case "json":
+		b, err := json.MarshalIndent(tx, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	default:
+		return fmt.Errorf("unknown format: %v", format)
+	}
+	return nil
+}
+
+func parseTx(s string) (*transaction.Tx, error) {
+	if strings.HasPrefix(s, string(crypto.PrefixTransaction)) {
+		return transaction.FromNyzoString(s)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx: %v", err)
+	}
+	tx := &transaction.Tx{}
+	if err := tx.Deserialize(b); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// parseAmount converts an amount in nyzos to micronyzos
+func parseAmount(s string) (int64, error) {
+	parts := strings.SplitN(s, ".", 2)
+	// ParseInt would take a sign in either part
+	if !isDigits(parts[0]) || len(parts) == 2 && !isDigits(parts[1]) {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	whole, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+
+	var fraction int64
+	if len(parts) == 2 {
+		if len(parts[1]) > 6 {
+			return 0, fmt.Errorf("invalid amount: %q", s)
+		}
+		digits := parts[1] + strings.Repeat("0", 6-len(parts[1]))
+		if fraction, err = strconv.ParseInt(digits, 10, 64); err != nil {
+			return 0, fmt.Errorf("invalid amount: %q", s)
+		}
+	}
+
+	micros := whole*microNyzos + fraction
+	if micros/microNyzos != whole || micros <= 0 {
+		return 0, fmt.Errorf("invalid amount: %q", s)
+	}
+	return micros, nil
+}
+
+// isDigits is true for a non-empty string of only 0-9
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
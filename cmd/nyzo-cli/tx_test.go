@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s      string
+		micros int64
+		valid  bool
+	}{
+		{"1", 1000000, true},
+		{"1.5", 1500000, true},
+		{"0.000001", 1, true},
+		{"10.123456", 10123456, true},
+		{"0", 0, false},
+		{"", 0, false},
+		{"1.", 0, false},
+		{"1.1234567", 0, false},
+		{"-1", 0, false},
+		{"1.-5", 0, false},
+		{"1.+5", 0, false},
+		{"+1", 0, false},
+		{"1.5 ", 0, false},
+		{".5", 0, false},
+		{"abc", 0, false},
+		{"99999999999999", 0, false},
+	}
+
+	for _, test := range tests {
+		micros, err := parseAmount(test.s)
+		if test.valid && (err != nil || micros != test.micros) {
+			t.Errorf("parseAmount(%q) = %v, %v; expected %v", test.s, micros, err, test.micros)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected parseAmount(%q) to fail", test.s)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/logging"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+const configFile = "nyzod.json"
+
+const (
+	ModeVerifier = "verifier"
+	ModeClient   = "client"
+)
+
+type Config struct {
+	// DataDir overrides files.AppDataDir()
+	DataDir string `json:"dataDir"`
+	// ListenAddr is the tcp address for messages, not used in client mode
+	ListenAddr string `json:"listenAddr"`
+	// RPCAddr is the http address for json rpc, empty to disable
+	RPCAddr string `json:"rpcAddr"`
+	// ExplorerAddr is the http address for the block explorer, empty to
+	// disable
+	ExplorerAddr string `json:"explorerAddr"`
+	// MetricsAddr is the http address for prometheus metrics on /metrics,
+	// empty to disable
+	MetricsAddr        string   `json:"metricsAddr"`
+	TrustedEntryPoints []string `json:"trustedEntryPoints"`
+	// AdminKeys are ids, hex or id__ strings, that may send admin requests
+	// besides the node's own
+	AdminKeys []string `json:"adminKeys"`
+	// AllowUpdates shuts the node down on an UpdateRequest from an admin
+	// with exit status 3, so a service manager that restarts on failure
+	// starts it again with a new binary. Otherwise update requests are
+	// only logged.
+	AllowUpdates bool `json:"allowUpdates"`
+	// LogLevel is a level for all subsystems with overrides for some, e.g.
+	// "info,network=debug", it is reloaded on SIGHUP
+	LogLevel string `json:"logLevel"`
+	// LogFormat is text or json
+	LogFormat string `json:"logFormat"`
+	// Mode is verifier or client. For now a verifier only answers
+	// messages and a client only serves rpc, the explorer and metrics,
+	// neither syncs or verifies blocks. Sentinel mode doesn't exist yet.
+	Mode string `json:"mode"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		ListenAddr: ":" + strconv.Itoa(network.DefaultPort),
+		LogLevel:   "info",
+		LogFormat:  logging.FormatText,
+		Mode:       ModeVerifier,
+	}
+}
+
+// loadConfig reads the config file at path on top of the defaults. A
+// missing file is only an error if mustExist is set.
+func loadConfig(path string, mustExist bool) (*Config, error) {
+	cfg := defaultConfig()
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !mustExist {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %v: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %v: %v", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	switch cfg.Mode {
+	case ModeVerifier, ModeClient:
+	case "sentinel":
+		return fmt.Errorf("sentinel mode is not implemented")
+	default:
+		return fmt.Errorf("unknown mode %q", cfg.Mode)
+	}
+	if _, err := logging.ParseLevels(cfg.LogLevel); err != nil {
+		return err
+	}
+	switch cfg.LogFormat {
+	case logging.FormatText, logging.FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.LogFormat)
+	}
+	if _, err := cfg.adminKeys(); err != nil {
+		return err
+	}
+	if cfg.Mode != ModeClient && cfg.ListenAddr == "" {
+		return fmt.Errorf("listenAddr is required in %v mode", cfg.Mode)
+	}
+	return nil
+}
+
+func (cfg *Config) adminKeys() ([]crypto.PublicKey, error) {
+	keys := make([]crypto.PublicKey, len(cfg.AdminKeys))
+	for i, s := range cfg.AdminKeys {
+		var err error
+		if keys[i], err = crypto.ParseID(s); err != nil {
+			return nil, fmt.Errorf("invalid admin key %q: %v", s, err)
+		}
+	}
+	return keys, nil
+}
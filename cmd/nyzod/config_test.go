@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nyzod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "missing.json")
+	cfg, err := loadConfig(missing, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg, defaultConfig()) {
+		t.Errorf("expected default config, got %+v", cfg)
+	}
+	if _, err := loadConfig(missing, true); err == nil {
+		t.Error("expected a missing config to fail when it must exist")
+	}
+
+	tests := []struct {
+		json  string
+		valid bool
+	}{
+		{`{"mode": "verifier", "logLevel": "debug", "trustedEntryPoints": ["a:9444"]}`, true},
+		{`{"mode": "sentinel"}`, false},
+		{`{"mode": "client", "listenAddr": ""}`, true},
+		{`{"mode": "verifier", "listenAddr": ""}`, false},
+		{`{"mode": "miner"}`, false},
+		{`{"logLevel": "loud"}`, false},
+		{`{"logLevel": "warn,block=debug", "logFormat": "json"}`, true},
+		{`{"logLevel": "info,=debug"}`, false},
+		{`{"logFormat": "xml"}`, false},
+		{`{"adminKeys": ["` + strings.Repeat("ab", 32) + `"]}`, true},
+		{`{"adminKeys": ["abcd"]}`, false},
+		{`{`, false},
+	}
+
+	for i, test := range tests {
+		path := filepath.Join(dir, "nyzod.json")
+		if err := ioutil.WriteFile(path, []byte(test.json), 0600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadConfig(path, true)
+		if test.valid && err != nil {
+			t.Errorf("loading config failed (%v): %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected loading config to fail (%v)", i)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/qqvv/go-nyzo/admin"
+	"github.com/qqvv/go-nyzo/blacklist"
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/db"
+	"github.com/qqvv/go-nyzo/explorer"
+	"github.com/qqvv/go-nyzo/files"
+	"github.com/qqvv/go-nyzo/logging"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/metrics"
+	"github.com/qqvv/go-nyzo/network"
+	"github.com/qqvv/go-nyzo/rpc"
+	"github.com/qqvv/go-nyzo/timesync"
+	"github.com/qqvv/go-nyzo/verifier"
+)
+
+const dbFile = "blocks.db"
+
+var errUpdateRequested = errors.New("update requested")
+
+// exitUpdate is the exit status after an update request, it is not 0 so
+// a service manager that restarts on failure starts the new binary
+const exitUpdate = 3
+
+var log = logging.Logger("nyzod")
+
+type node struct {
+	cfg     *Config
+	privKey crypto.PrivateKey
+	store   *db.Store
+
+	msgServer      *network.Server
+	rpcServer      *http.Server
+	explorerServer *http.Server
+	metricsServer  *http.Server
+	timeSyncer     *timesync.Syncer
+	blacklist      *blacklist.List
+	admin          *admin.Handlers
+	errs           chan error
+}
+
+func main() {
+	configPath := flag.String("config", "", "config file (default <datadir>/"+configFile+")")
+	dataDir := flag.String("datadir", "", "data directory, overrides the config file")
+	flag.Parse()
+
+	cfg, err := readConfig(*configPath, *dataDir)
+	if err != nil {
+		fatal(err)
+	}
+	if err := logging.SetOutput(os.Stderr, cfg.LogFormat); err != nil {
+		fatal(err)
+	}
+	if err := logging.SetLevels(cfg.LogLevel); err != nil {
+		fatal(err)
+	}
+
+	n, err := start(cfg)
+	if err != nil {
+		fatal(err)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	code := 0
+	for {
+		select {
+		case s := <-sig:
+			if s == syscall.SIGHUP {
+				reloadLogLevels(*configPath, *dataDir)
+				continue
+			}
+			log.Info("shutting down", "signal", s)
+		case err := <-n.errs:
+			if err == errUpdateRequested {
+				log.Info("shutting down for an update", "exitStatus", exitUpdate)
+				code = exitUpdate
+				break
+			}
+			log.Error("shutting down", "err", err)
+			code = 1
+		}
+		break
+	}
+
+	n.stop()
+	os.Exit(code)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
+
+// reloadLogLevels applies the log levels of the config file again, the
+// rest of the config only takes effect on restart
+func reloadLogLevels(configPath, dataDir string) {
+	cfg, err := readConfig(configPath, dataDir)
+	if err != nil {
+		log.Error("reloading config", "err", err)
+		return
+	}
+	if err := logging.SetLevels(cfg.LogLevel); err != nil {
+		log.Error("reloading config", "err", err)
+		return
+	}
+	log.Info("reloaded log levels", "logLevel", cfg.LogLevel)
+}
+
+func readConfig(configPath, dataDir string) (*Config, error) {
+	mustExist := configPath != ""
+	if configPath == "" {
+		dir := dataDir
+		if dir == "" {
+			dir = files.AppDataDir()
+		}
+		configPath = filepath.Join(dir, configFile)
+	}
+
+	cfg, err := loadConfig(configPath, mustExist)
+	if err != nil {
+		return nil, err
+	}
+	if dataDir != "" {
+		cfg.DataDir = dataDir
+	}
+	return cfg, nil
+}
+
+func start(cfg *Config) (*node, error) {
+	n := &node{cfg: cfg, errs: make(chan error, 4)}
+
+	if cfg.DataDir != "" {
+		if err := files.SetDataDir(cfg.DataDir); err != nil {
+			return nil, err
+		}
+	}
+	log.Info("starting", "version", verifier.Version, "mode", cfg.Mode, "dataDir", files.DataDir())
+
+	var err error
+	n.privKey, err = verifier.LoadOrCreatePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	log.Info("loaded private key", "id", n.privKey.PubKey().StringCompact(), "nyzoString", n.privKey.PubKey().NyzoString())
+
+	n.store, err = db.Open(filepath.Join(files.DataDir(), dbFile))
+	if err != nil {
+		return nil, err
+	}
+	if height, err := n.store.FrozenEdgeHeight(); err == nil {
+		log.Info("opened block store", "frozenEdge", height)
+	} else {
+		log.Info("opened block store", "frozenEdge", "none")
+	}
+	if err := n.checkHistory(); err != nil {
+		return nil, err
+	}
+
+	// TODO: the blockchain, mesh, tx pool and voting don't exist yet, so
+	// the trusted entry points are only recorded and nothing is synced, and
+	// the vote, pool and mesh metrics stay at 0
+	if len(cfg.TrustedEntryPoints) > 0 {
+		log.Info("trusted entry points", "addrs", strings.Join(cfg.TrustedEntryPoints, ","))
+	}
+
+	// the messages of the node are timestamped with the median time of the
+	// trusted entry points, which are the only peers known for now
+	peers := make([]string, len(cfg.TrustedEntryPoints))
+	for i, addr := range cfg.TrustedEntryPoints {
+		peers[i] = network.WithDefaultPort(addr)
+	}
+	median := clock.NewMedian(clock.System{})
+	n.timeSyncer = timesync.New(median, func() []string { return peers })
+	if len(peers) > 0 {
+		go n.timeSyncer.Run()
+	}
+
+	if cfg.Mode != ModeClient {
+		n.blacklist, err = blacklist.Load(blacklist.DefaultFile)
+		if err != nil {
+			return nil, err
+		}
+		n.msgServer = network.NewServer(n.privKey)
+		n.blacklist.Clock = median
+		n.msgServer.SetBlacklist(n.blacklist)
+		pinger := verifier.NewPinger(n.store)
+		pinger.Clock = median
+		n.msgServer.Handle(message.Ping, pinger.HandlePing)
+		n.msgServer.Handle(message.TimestampRequest, n.timeSyncer.HandleTimestampRequest)
+		n.msgServer.Handle(message.BlacklistStatusRequest, n.blacklist.HandleBlacklistStatusRequest)
+		// validated with the config already
+		adminKeys, _ := cfg.adminKeys()
+		n.admin = admin.New(n.privKey.PubKey(), adminKeys)
+		n.admin.Clock = median
+		if cfg.AllowUpdates {
+			n.admin.Update = func() {
+				select {
+				case n.errs <- errUpdateRequested:
+				default:
+				}
+			}
+		}
+		n.admin.Register(n.msgServer)
+		go func() {
+			log.Info("listening for messages", "addr", cfg.ListenAddr)
+			if err := n.msgServer.ListenAndServe(cfg.ListenAddr); err != network.ErrServerClosed {
+				n.errs <- fmt.Errorf("message server: %v", err)
+			}
+		}()
+	}
+
+	if cfg.RPCAddr != "" {
+		n.rpcServer = &http.Server{
+			Addr:    cfg.RPCAddr,
+			Handler: rpc.NewServer(n.store, n.store, nil, nil),
+		}
+		go func() {
+			log.Info("listening for json rpc", "addr", cfg.RPCAddr)
+			if err := n.rpcServer.ListenAndServe(); err != http.ErrServerClosed {
+				n.errs <- fmt.Errorf("rpc server: %v", err)
+			}
+		}()
+	}
+
+	if cfg.ExplorerAddr != "" {
+		n.explorerServer = &http.Server{
+			Addr:    cfg.ExplorerAddr,
+			Handler: explorer.NewServer(n.store, n.store, n.store),
+		}
+		go func() {
+			log.Info("listening for block explorer", "addr", cfg.ExplorerAddr)
+			if err := n.explorerServer.ListenAndServe(); err != http.ErrServerClosed {
+				n.errs <- fmt.Errorf("explorer server: %v", err)
+			}
+		}()
+	}
+
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		n.metricsServer = &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+		go func() {
+			log.Info("serving metrics", "addr", cfg.MetricsAddr, "path", "/metrics")
+			if err := n.metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				n.errs <- fmt.Errorf("metrics server: %v", err)
+			}
+		}()
+	}
+
+	return n, nil
+}
+
+// checkHistory rebuilds the tx history index if it is behind the frozen
+// edge, e.g. for a block store from before the index existed
+func (n *node) checkHistory() error {
+	edge, err := n.store.FrozenEdgeHeight()
+	if err == db.ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if height, err := n.store.HistoryEdgeHeight(); err == nil && height == edge {
+		return nil
+	}
+
+	log.Info("rebuilding tx history index", "height", edge)
+	start := time.Now()
+	if err := n.store.RebuildHistory(); err != nil {
+		return fmt.Errorf("rebuilding tx history: %v", err)
+	}
+	log.Info("rebuilt tx history index", "elapsed", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// stop shuts everything down in reverse order, in-flight requests and
+// file writes are completed first
+func (n *node) stop() {
+	n.timeSyncer.Stop()
+	if n.msgServer != nil {
+		n.msgServer.Close()
+	}
+	for name, srv := range map[string]*http.Server{
+		"rpc":      n.rpcServer,
+		"explorer": n.explorerServer,
+		"metrics":  n.metricsServer,
+	} {
+		if srv == nil {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Warn("server shutdown", "server", name, "err", err)
+		}
+		cancel()
+	}
+
+	if n.blacklist != nil {
+		if err := n.blacklist.Save(); err != nil {
+			log.Error("saving blacklist", "err", err)
+		}
+	}
+	files.Shutdown()
+	if err := n.store.Close(); err != nil {
+		log.Error("closing block store", "err", err)
+	}
+	log.Info("shut down")
+}
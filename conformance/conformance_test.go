@@ -0,0 +1,33 @@
+package conformance
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// TestConformance checks the captured vectors in testdata, generated ones
+// are rejected since they only compare the code with itself
+func TestConformance(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no captured vectors in testdata, see testdata/README.md")
+	}
+
+	for _, path := range paths {
+		v, err := ReadVector(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if v.Source == "" || v.Source == SourceGenerated {
+				t.Fatalf("source %q is not a capture, generated vectors belong in roundtrip", v.Source)
+			}
+			if err := v.Check(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package conformance checks the wire types against vectors captured from
+// nyzoVerifier or mainnet, so the encoding stays compatible with the Java
+// reference implementation. The vectors are in testdata, Vector is also
+// used by the round trip tests of generated vectors.
+package conformance
@@ -0,0 +1,135 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+// SourceGenerated marks vectors built by this code, they can't show a
+// mismatch with nyzoVerifier and are only used for round trip tests
+const SourceGenerated = "generated"
+
+// Vector is a serialized wire type with the values it must decode to
+type Vector struct {
+	Description string `json:"description"`
+	// Source is where the bytes come from, e.g. "mainnet block 4500000
+	// from nyzoVerifier v620"
+	Source string `json:"source"`
+	Kind   string `json:"kind"` // tx, block, balancelist or message
+	Hex    string `json:"hex"`
+	Hash   string `json:"hash,omitempty"`
+	// PrevHash is the hash of the block at the PrevHashHeight of a tx, it
+	// is signed but not serialized
+	PrevHash string `json:"prevHash,omitempty"`
+	SigValid *bool  `json:"sigValid,omitempty"`
+}
+
+func ReadVector(path string) (*Vector, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v := &Vector{}
+	if err := json.Unmarshal(b, v); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	return v, nil
+}
+
+// Check deserializes the bytes, serializes them again and compares the
+// result, the hash and the signature validity with the vector
+func (v *Vector) Check() error {
+	b, err := hex.DecodeString(v.Hex)
+	if err != nil {
+		return fmt.Errorf("invalid hex: %v", err)
+	}
+
+	var serialized []byte
+	var hash *crypto.Hash
+	var sigValid *bool
+
+	switch v.Kind {
+	case "tx":
+		tx := &transaction.Tx{}
+		if err := tx.Deserialize(b); err != nil {
+			return err
+		}
+		serialized = tx.Serialize()
+		h := tx.Hash()
+		hash = &h
+		if tx.Type != 0 && v.PrevHash != "" {
+			if tx.PrevHash, err = crypto.ParseHash(v.PrevHash); err != nil {
+				return fmt.Errorf("invalid prev hash: %v", err)
+			}
+			valid := tx.SenderID.Verify(tx.ForSigning(), tx.SenderSig)
+			sigValid = &valid
+		}
+	case "block":
+		bl := &block.Block{}
+		if err := bl.DeserializeBlock(b); err != nil {
+			return err
+		}
+		serialized = bl.Serialize()
+		h := bl.Hash()
+		hash = &h
+		valid := bl.VerifierID.Verify(bl.ForSigning(), bl.VerifierSig)
+		sigValid = &valid
+	case "balancelist":
+		list := &balancelist.List{}
+		if err := list.Deserialize(b); err != nil {
+			return err
+		}
+		serialized = list.Serialize()
+		h := crypto.DoubleSHA256(serialized)
+		hash = &h
+	case "message":
+		if len(b) < 4 || int(binary.BigEndian.Uint32(b)) != len(b) {
+			return fmt.Errorf("length prefix does not match the message length %v", len(b))
+		}
+		msg := &message.Msg{}
+		if err := msg.Deserialize(b[4:]); err != nil {
+			return err
+		}
+		serialized = msg.Serialize()
+		valid := msg.VerifySig()
+		sigValid = &valid
+	default:
+		return fmt.Errorf("unknown kind %q", v.Kind)
+	}
+
+	if !bytes.Equal(serialized, b) {
+		return fmt.Errorf("serialization does not match\nexpected %x\ngot      %x", b, serialized)
+	}
+	if v.Hash != "" {
+		if hash == nil {
+			return fmt.Errorf("%v has no hash", v.Kind)
+		}
+		if got := HashString(*hash); got != v.Hash {
+			return fmt.Errorf("expected hash %v, got %v", v.Hash, got)
+		}
+	}
+	if v.SigValid != nil {
+		if sigValid == nil {
+			return fmt.Errorf("%v has no signature", v.Kind)
+		}
+		if *sigValid != *v.SigValid {
+			return fmt.Errorf("expected signature validity %v, got %v", *v.SigValid, *sigValid)
+		}
+	}
+	return nil
+}
+
+// HashString is lowercase hex like the json encoding of a Hash
+func HashString(h crypto.Hash) string {
+	return hex.EncodeToString(h[:])
+}
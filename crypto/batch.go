@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+type VerifyItem struct {
+	PubKey PublicKey
+	Msg    []byte
+	Sig    Signature
+}
+
+// BatchVerify verifies items in parallel on all CPUs and returns the
+// indexes of the invalid ones in ascending order
+func BatchVerify(items []VerifyItem) []int {
+	valid := make([]bool, len(items))
+
+	workers := runtime.NumCPU()
+	if workers > len(items) {
+		workers = len(items)
+	}
+
+	// workers take the next unverified item until none are left
+	var next int64 = -1
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= len(items) {
+					return
+				}
+				valid[i] = items[i].PubKey.Verify(items[i].Msg, items[i].Sig)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []int
+	for i, v := range valid {
+		if !v {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 )
 
@@ -16,3 +17,31 @@ func DoubleSHA256(data []byte) Hash {
 	h = sha256.Sum256(h[:])
 	return Hash(h)
 }
+
+func (h Hash) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%x", h[:]))
+}
+
+func (h *Hash) UnmarshalJSON(data []byte) error {
+	var x string
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	hash, err := ParseHash(x)
+	if err != nil {
+		return err
+	}
+	*h = hash
+	return nil
+}
+
+// ParseHash parses a Hash in plain hex or the dashed format
+func ParseHash(s string) (Hash, error) {
+	h := Hash{}
+	b, err := decodeHex(s, len(h))
+	if err != nil {
+		return h, err
+	}
+	copy(h[:], b)
+	return h, nil
+}
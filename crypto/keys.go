@@ -4,6 +4,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync/atomic"
 
 	"golang.org/x/crypto/ed25519"
 )
@@ -58,9 +60,22 @@ func (privKey PrivateKey) Sign(msg []byte) Signature {
 	return sig
 }
 
+// verifyFailures counts the signatures Verify rejected
+var verifyFailures uint64
+
 func (pubKey PublicKey) Verify(msg []byte, sig Signature) bool {
 	edPubKey := ed25519.PublicKey(pubKey[:])
-	return ed25519.Verify(edPubKey, msg, sig[:])
+	valid := ed25519.Verify(edPubKey, msg, sig[:])
+	if !valid {
+		atomic.AddUint64(&verifyFailures, 1)
+	}
+	return valid
+}
+
+// VerifyFailures is the number of invalid signatures seen so far, results
+// served from a SigCache are not counted again
+func VerifyFailures() uint64 {
+	return atomic.LoadUint64(&verifyFailures)
 }
 
 func (pubKey PublicKey) String() string {
@@ -81,6 +96,11 @@ func (privKey PrivateKey) String() string {
 	return fmt.Sprintf("%x", privKey[:32])
 }
 
+func (privKey PrivateKey) StringWithDashes() string {
+	x := privKey.String()
+	return fmt.Sprintf("%v-%v-%v-%v", x[:16], x[16:32], x[32:48], x[48:])
+}
+
 func (sig Signature) String() string {
 	return fmt.Sprintf("%x", sig[:])
 }
@@ -102,42 +122,75 @@ func (sig Signature) MarshalJSON() ([]byte, error) {
 
 func (pubKey *PublicKey) UnmarshalJSON(data []byte) error {
 	var x string
-	err := json.Unmarshal(data, x)
-	if err != nil {
+	if err := json.Unmarshal(data, &x); err != nil {
 		return err
 	}
-	b, err := hex.DecodeString(x)
+	k, err := ParsePublicKey(x)
 	if err != nil {
 		return err
 	}
-	copy(pubKey[:], b)
+	*pubKey = k
 	return nil
 }
 
+// Only the seed is marshalled, the PublicKey part is derived from it again
 func (privKey *PrivateKey) UnmarshalJSON(data []byte) error {
 	var x string
-	err := json.Unmarshal(data, x)
-	if err != nil {
+	if err := json.Unmarshal(data, &x); err != nil {
 		return err
 	}
-	b, err := hex.DecodeString(x)
+	k, err := ParsePrivateKey(x)
 	if err != nil {
 		return err
 	}
-	copy(privKey[:], b)
+	*privKey = k
 	return nil
 }
 
 func (sig *Signature) UnmarshalJSON(data []byte) error {
 	var x string
-	err := json.Unmarshal(data, x)
-	if err != nil {
+	if err := json.Unmarshal(data, &x); err != nil {
 		return err
 	}
-	b, err := hex.DecodeString(x)
+	b, err := decodeHex(x, len(sig))
 	if err != nil {
 		return err
 	}
 	copy(sig[:], b)
 	return nil
 }
+
+// ParsePublicKey parses a PublicKey in plain hex or the dashed format
+func ParsePublicKey(s string) (PublicKey, error) {
+	pubKey := PublicKey{}
+	b, err := decodeHex(s, len(pubKey))
+	if err != nil {
+		return pubKey, err
+	}
+	copy(pubKey[:], b)
+	return pubKey, nil
+}
+
+// ParsePrivateKey parses a private seed in plain hex or the dashed format
+func ParsePrivateKey(s string) (PrivateKey, error) {
+	privKey := PrivateKey{}
+	b, err := decodeHex(s, 32)
+	if err != nil {
+		return privKey, err
+	}
+	copy(privKey[:], b)
+	privKey.SetPubKey()
+	return privKey, nil
+}
+
+// decodeHex decodes exactly n bytes, ignoring dashes
+func decodeHex(s string, n int) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != n {
+		return nil, fmt.Errorf("invalid length: expected %v bytes, got %v", n, len(b))
+	}
+	return b, nil
+}
@@ -2,6 +2,8 @@ package crypto
 
 import (
 	"bytes"
+	"encoding/json"
+	"reflect"
 	"testing"
 )
 
@@ -24,3 +26,149 @@ func TestPubKey(t *testing.T) {
 		t.Error("new PublicKey doesn't match")
 	}
 }
+
+func TestUnmarshalJSON(t *testing.T) {
+	privKey := GenPrivKey()
+	pubKey := privKey.PubKey()
+	sig := privKey.Sign([]byte("test"))
+	hash := DoubleSHA256([]byte("test"))
+
+	tests := []struct {
+		json  string
+		v     interface{}
+		valid bool
+	}{
+		{`"` + pubKey.String() + `"`, new(PublicKey), true},
+		{`"` + pubKey.StringWithDashes() + `"`, new(PublicKey), true},
+		{`"` + pubKey.String()[2:] + `"`, new(PublicKey), false},
+		{`"` + pubKey.String() + `00"`, new(PublicKey), false},
+		{`"` + pubKey.String()[1:] + `x"`, new(PublicKey), false},
+		{pubKey.String(), new(PublicKey), false},
+		{`"` + privKey.String() + `"`, new(PrivateKey), true},
+		{`"` + privKey.String() + pubKey.String() + `"`, new(PrivateKey), false},
+		{`"` + sig.String() + `"`, new(Signature), true},
+		{`"` + sig.String()[:64] + `"`, new(Signature), false},
+		{`"` + hash.String() + `"`, new(Hash), true},
+		{`""`, new(Hash), false},
+	}
+
+	for i, test := range tests {
+		err := json.Unmarshal([]byte(test.json), test.v)
+		if test.valid && err != nil {
+			t.Errorf("unmarshalling failed (%v): %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected unmarshalling to fail (%v)", i)
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	privKey := GenPrivKey()
+
+	x := struct {
+		PubKey  PublicKey
+		PrivKey PrivateKey
+		Sig     Signature
+		Hash    Hash
+	}{
+		privKey.PubKey(),
+		privKey,
+		privKey.Sign([]byte("test")),
+		DoubleSHA256([]byte("test")),
+	}
+
+	b, err := json.Marshal(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := x
+	y.PubKey, y.PrivKey, y.Sig, y.Hash = PublicKey{}, PrivateKey{}, Signature{}, Hash{}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+	if x != y {
+		t.Errorf("JSON round trip does not match: %+v != %+v", x, y)
+	}
+}
+
+func TestBatchVerify(t *testing.T) {
+	if failed := BatchVerify(nil); len(failed) != 0 {
+		t.Errorf("expected no failures for an empty batch, got %v", failed)
+	}
+
+	items := make([]VerifyItem, 100)
+	for i := range items {
+		privKey := GenPrivKey()
+		msg := RandBytes(64)
+		items[i] = VerifyItem{privKey.PubKey(), msg, privKey.Sign(msg)}
+	}
+	items[3].Msg = RandBytes(64)
+	items[42].Sig[0] ^= 0xff
+	items[99].PubKey = GenPrivKey().PubKey()
+
+	failed := BatchVerify(items)
+	if !reflect.DeepEqual(failed, []int{3, 42, 99}) {
+		t.Errorf("expected items 3, 42 and 99 to fail, got %v", failed)
+	}
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	items := make([]VerifyItem, 1000)
+	for i := range items {
+		privKey := GenPrivKey()
+		msg := RandBytes(64)
+		items[i] = VerifyItem{privKey.PubKey(), msg, privKey.Sign(msg)}
+	}
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		BatchVerify(items)
+	}
+}
+
+func TestSigCache(t *testing.T) {
+	c := NewSigCache(2)
+	privKey := GenPrivKey()
+	msgs := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	sigs := []Signature{}
+	for _, msg := range msgs {
+		sigs = append(sigs, privKey.Sign(msg))
+	}
+	pubKey := privKey.PubKey()
+
+	if !c.Verify(pubKey, msgs[0], sigs[0]) || !c.Verify(pubKey, msgs[0], sigs[0]) {
+		t.Error("expected valid signature")
+	}
+	if c.Verify(pubKey, msgs[1], sigs[0]) || c.Verify(pubKey, msgs[1], sigs[0]) {
+		t.Error("expected invalid signature to stay invalid when cached")
+	}
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Len != 2 || stats.HitRate() != 0.5 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// evicts the least recently used entry, the invalid one
+	c.Verify(pubKey, msgs[0], sigs[0])
+	c.Verify(pubKey, msgs[2], sigs[2])
+	stats = c.Stats()
+	if stats.Evictions != 1 || stats.Len != 2 {
+		t.Errorf("unexpected stats after eviction %+v", stats)
+	}
+	if _, ok := c.Get(SigCacheKey(pubKey, msgs[0], sigs[0])); !ok {
+		t.Error("expected recently used entry to be cached")
+	}
+	if _, ok := c.Get(SigCacheKey(pubKey, msgs[1], sigs[0])); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+
+	// a different key with the same msg and sig is not a hit
+	if c.Verify(GenPrivKey().PubKey(), msgs[0], sigs[0]) {
+		t.Error("expected signature to be invalid for another key")
+	}
+
+	var nilCache *SigCache
+	if !nilCache.Verify(pubKey, msgs[0], sigs[0]) {
+		t.Error("expected nil cache to verify")
+	}
+}
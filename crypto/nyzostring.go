@@ -0,0 +1,193 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Nyzo strings are the user facing format of keys, ids and txes, e.g.
+// id__8dcP0nvh.... They consist of a 4 char prefix, a length byte, the
+// content and a 4-6 byte checksum, encoded with 6 bits per char.
+type NyzoStringPrefix string
+
+const (
+	PrefixPrivateSeed   NyzoStringPrefix = "key_"
+	PrefixPublicID      NyzoStringPrefix = "id__"
+	PrefixPrefilledData NyzoStringPrefix = "pre_"
+	PrefixTransaction   NyzoStringPrefix = "tx__"
+)
+
+// no lowercase L and no uppercase O
+const nyzoStringChars = "0123456789" +
+	"abcdefghijkmnopqrstuvwxyz" +
+	"ABCDEFGHIJKLMNPQRSTUVWXYZ" +
+	"-.~_"
+
+var nyzoStringValues = func() map[byte]int {
+	m := make(map[byte]int, len(nyzoStringChars))
+	for i := 0; i < len(nyzoStringChars); i++ {
+		m[nyzoStringChars[i]] = i
+	}
+	return m
+}()
+
+func EncodeNyzoString(prefix NyzoStringPrefix, content []byte) string {
+	if len(content) > 255 {
+		panic("nyzo string content too long")
+	}
+
+	// widen the checksum so the total length is divisible by 3, that
+	// way the encoded string has no padding
+	checksumLen := 4 + (3-(len(content)+2)%3)%3
+
+	expanded := make([]byte, 0, 4+len(content)+checksumLen)
+	expanded = append(expanded, nyzoStringBytes(string(prefix))...)
+	expanded = append(expanded, byte(len(content)))
+	expanded = append(expanded, content...)
+	checksum := DoubleSHA256(expanded)
+	expanded = append(expanded, checksum[:checksumLen]...)
+
+	return encodeNyzoStringBytes(expanded)
+}
+
+func DecodeNyzoString(s string) (NyzoStringPrefix, []byte, error) {
+	// map chars from the old encoding and chars that may be mistyped
+	s = strings.NewReplacer("*", "-", "+", ".", "=", "~", "l", "1", "O", "0").Replace(s)
+
+	if len(s) < 4 {
+		return "", nil, fmt.Errorf("nyzo string too short")
+	}
+	prefix := NyzoStringPrefix(s[:4])
+	switch prefix {
+	case PrefixPrivateSeed, PrefixPublicID, PrefixPrefilledData, PrefixTransaction:
+	default:
+		return "", nil, fmt.Errorf("unknown nyzo string prefix: %v", prefix)
+	}
+	for i := 0; i < len(s); i++ {
+		if _, ok := nyzoStringValues[s[i]]; !ok {
+			return "", nil, fmt.Errorf("invalid nyzo string char: %q", s[i])
+		}
+	}
+
+	expanded := nyzoStringBytes(s)
+	if len(expanded) < 4 {
+		return "", nil, fmt.Errorf("nyzo string too short")
+	}
+	contentLen := int(expanded[3])
+	checksumLen := len(expanded) - contentLen - 4
+	if checksumLen < 4 || checksumLen > 6 {
+		return "", nil, fmt.Errorf("invalid nyzo string length")
+	}
+
+	checksum := DoubleSHA256(expanded[:4+contentLen])
+	if !bytes.Equal(checksum[:checksumLen], expanded[4+contentLen:]) {
+		return "", nil, fmt.Errorf("invalid nyzo string checksum")
+	}
+
+	return prefix, expanded[4 : 4+contentLen], nil
+}
+
+// DecodeNyzoStringPrefix decodes a nyzo string and checks its prefix
+func DecodeNyzoStringPrefix(s string, prefix NyzoStringPrefix) ([]byte, error) {
+	p, content, err := DecodeNyzoString(s)
+	if err != nil {
+		return nil, err
+	}
+	if p != prefix {
+		return nil, fmt.Errorf("expected nyzo string prefix %v, got %v", prefix, p)
+	}
+	return content, nil
+}
+
+func (pubKey PublicKey) NyzoString() string {
+	return EncodeNyzoString(PrefixPublicID, pubKey[:])
+}
+
+func (privKey PrivateKey) NyzoString() string {
+	return EncodeNyzoString(PrefixPrivateSeed, privKey[:32])
+}
+
+func ParseNyzoPublicKey(s string) (PublicKey, error) {
+	pubKey := PublicKey{}
+	b, err := DecodeNyzoStringPrefix(s, PrefixPublicID)
+	if err != nil {
+		return pubKey, err
+	}
+	if len(b) != len(pubKey) {
+		return pubKey, fmt.Errorf("invalid length: expected %v bytes, got %v", len(pubKey), len(b))
+	}
+	copy(pubKey[:], b)
+	return pubKey, nil
+}
+
+func ParseNyzoPrivateKey(s string) (PrivateKey, error) {
+	privKey := PrivateKey{}
+	b, err := DecodeNyzoStringPrefix(s, PrefixPrivateSeed)
+	if err != nil {
+		return privKey, err
+	}
+	if len(b) != 32 {
+		return privKey, fmt.Errorf("invalid length: expected 32 bytes, got %v", len(b))
+	}
+	copy(privKey[:], b)
+	privKey.SetPubKey()
+	return privKey, nil
+}
+
+// ParseID parses an id given as id__ Nyzo string or in hex
+func ParseID(s string) (PublicKey, error) {
+	if strings.HasPrefix(s, string(PrefixPublicID)) {
+		return ParseNyzoPublicKey(s)
+	}
+	return ParsePublicKey(s)
+}
+
+// ParseSeed parses a private seed given as key_ Nyzo string or in hex
+func ParseSeed(s string) (PrivateKey, error) {
+	if strings.HasPrefix(s, string(PrefixPrivateSeed)) {
+		return ParseNyzoPrivateKey(s)
+	}
+	return ParsePrivateKey(s)
+}
+
+func encodeNyzoStringBytes(b []byte) string {
+	var s strings.Builder
+	s.Grow((len(b)*8 + 5) / 6)
+
+	index, bitOffset := 0, 0
+	for index < len(b) {
+		left := int(b[index])
+		right := 0
+		if index < len(b)-1 {
+			right = int(b[index+1])
+		}
+
+		s.WriteByte(nyzoStringChars[((left<<8)+right)>>uint(10-bitOffset)&0x3f])
+
+		// advance 6 bits
+		if bitOffset == 0 {
+			bitOffset = 6
+		} else {
+			index++
+			bitOffset -= 2
+		}
+	}
+
+	return s.String()
+}
+
+// chars must have been checked to be valid
+func nyzoStringBytes(s string) []byte {
+	b := make([]byte, (len(s)*6+7)/8)
+	for i := range b {
+		left := nyzoStringValues[s[i*8/6]]
+		right := 0
+		if i*8/6 < len(s)-1 {
+			right = nyzoStringValues[s[i*8/6+1]]
+		}
+		bitOffset := (i * 2) % 6
+		b[i] = byte(((left << 6) + right) >> uint(4-bitOffset))
+	}
+	return b
+}
@@ -0,0 +1,183 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNyzoStringRoundTrip(t *testing.T) {
+	for n := 0; n < 70; n++ {
+		content := RandBytes(n)
+		for _, prefix := range []NyzoStringPrefix{PrefixPrivateSeed, PrefixPublicID,
+			PrefixPrefilledData, PrefixTransaction} {
+			s := EncodeNyzoString(prefix, content)
+			if !strings.HasPrefix(s, string(prefix)) {
+				t.Errorf("expected %v to start with %v", s, prefix)
+			}
+			p, b, err := DecodeNyzoString(s)
+			if err != nil {
+				t.Fatalf("decoding %v failed: %v", s, err)
+			}
+			if p != prefix || !bytes.Equal(b, content) {
+				t.Errorf("nyzo string round trip does not match for %v", s)
+			}
+		}
+	}
+}
+
+func TestNyzoStringKeys(t *testing.T) {
+	privKey := GenPrivKey()
+	pubKey := privKey.PubKey()
+
+	newPrivKey, err := ParseNyzoPrivateKey(privKey.NyzoString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPrivKey != privKey {
+		t.Error("PrivateKey nyzo string round trip does not match")
+	}
+
+	newPubKey, err := ParseNyzoPublicKey(pubKey.NyzoString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newPubKey != pubKey {
+		t.Error("PublicKey nyzo string round trip does not match")
+	}
+
+	if _, err := ParseNyzoPublicKey(privKey.NyzoString()); err == nil {
+		t.Error("expected a key_ string to be rejected as an id")
+	}
+}
+
+// walletKey is a key as the Java wallet shows it, see testdata/README.md
+type walletKey struct {
+	Source string `json:"source"`
+	Seed   string `json:"seed"`
+	Key    string `json:"key"`
+	ID     string `json:"id"`
+	IDHex  string `json:"idHex"`
+}
+
+func TestNyzoStringWallet(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "wallet-*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no wallet keys in testdata, see testdata/README.md")
+	}
+
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := &walletKey{}
+		if err := json.Unmarshal(b, w); err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if w.Source == "" {
+				t.Fatal("the source of the key is missing")
+			}
+			privKey, err := ParsePrivateKey(w.Seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pubKey, err := ParsePublicKey(w.IDHex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if privKey.PubKey() != pubKey {
+				t.Errorf("the seed does not belong to id %v", w.IDHex)
+			}
+			if s := privKey.NyzoString(); s != w.Key {
+				t.Errorf("expected key %v, got %v", w.Key, s)
+			}
+			if s := pubKey.NyzoString(); s != w.ID {
+				t.Errorf("expected id %v, got %v", w.ID, s)
+			}
+			if parsed, err := ParseNyzoPrivateKey(w.Key); err != nil || parsed != privKey {
+				t.Errorf("parsing key %v failed: %v", w.Key, err)
+			}
+			if parsed, err := ParseNyzoPublicKey(w.ID); err != nil || parsed != pubKey {
+				t.Errorf("parsing id %v failed: %v", w.ID, err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	privKey := GenPrivKey()
+	pubKey := privKey.PubKey()
+
+	for i, s := range []string{pubKey.String(), pubKey.StringWithDashes(), pubKey.NyzoString()} {
+		if id, err := ParseID(s); err != nil || id != pubKey {
+			t.Errorf("parsing id failed (%v): %v", i, err)
+		}
+	}
+	for i, s := range []string{privKey.String(), privKey.StringWithDashes(), privKey.NyzoString()} {
+		if seed, err := ParseSeed(s); err != nil || seed != privKey {
+			t.Errorf("parsing seed failed (%v): %v", i, err)
+		}
+	}
+	if _, err := ParseID(privKey.NyzoString()); err == nil {
+		t.Error("expected a key_ string to be rejected as an id")
+	}
+
+	h := DoubleSHA256([]byte("nyzo"))
+	if parsed, err := ParseHash(h.String()); err != nil || parsed != h {
+		t.Errorf("parsing hash failed: %v", err)
+	}
+	for i, s := range []string{"", "abcd", h.String() + "00", "zz"} {
+		if _, err := ParseHash(s); err == nil {
+			t.Errorf("expected parsing hash %q to fail (%v)", s, i)
+		}
+	}
+}
+
+func TestNyzoStringInvalid(t *testing.T) {
+	s := PublicKey{0x01}.NyzoString()
+
+	// flip one char in the content
+	c := byte('a')
+	if s[10] == 'a' {
+		c = 'b'
+	}
+	corrupted := s[:10] + string(c) + s[11:]
+
+	tests := []string{
+		"",
+		"id_",
+		"abc_" + s[4:],
+		s[:len(s)-1],
+		s + "0",
+		corrupted,
+		s[:20] + "!" + s[21:],
+	}
+
+	for i, test := range tests {
+		if _, _, err := DecodeNyzoString(test); err == nil {
+			t.Errorf("expected decoding to fail (%v): %v", i, test)
+		}
+	}
+}
+
+func TestNyzoStringMistyped(t *testing.T) {
+	for {
+		s := GenPrivKey().PubKey().NyzoString()
+		if !strings.ContainsAny(s, "01") {
+			continue
+		}
+		mistyped := strings.NewReplacer("1", "l", "0", "O").Replace(s)
+		if _, err := ParseNyzoPublicKey(mistyped); err != nil {
+			t.Errorf("expected mistyped nyzo string to decode: %v", err)
+		}
+		break
+	}
+}
@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"container/list"
+	"sync"
+)
+
+// SigCache is a bounded LRU cache of signature verification results. The
+// key covers the PublicKey, the hash of the message and the Signature, so a
+// result is only reused for exactly the same triple.
+type SigCache struct {
+	size int
+
+	mu        sync.Mutex
+	ll        *list.List
+	items     map[Hash]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+type sigCacheEntry struct {
+	key   Hash
+	valid bool
+}
+
+type SigCacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Len       int    `json:"len"`
+}
+
+func (s SigCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+func NewSigCache(size int) *SigCache {
+	if size < 1 {
+		panic("sig cache size must be at least 1")
+	}
+	return &SigCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[Hash]*list.Element, size),
+	}
+}
+
+func SigCacheKey(pubKey PublicKey, msg []byte, sig Signature) Hash {
+	msgHash := DoubleSHA256(msg)
+	b := make([]byte, 0, len(pubKey)+len(msgHash)+len(sig))
+	b = append(b, pubKey[:]...)
+	b = append(b, msgHash[:]...)
+	b = append(b, sig[:]...)
+	return DoubleSHA256(b)
+}
+
+// Verify is like PublicKey.Verify but uses the cached result if there is
+// one. A nil cache always verifies.
+func (c *SigCache) Verify(pubKey PublicKey, msg []byte, sig Signature) bool {
+	if c == nil {
+		return pubKey.Verify(msg, sig)
+	}
+
+	key := SigCacheKey(pubKey, msg, sig)
+	if valid, ok := c.Get(key); ok {
+		return valid
+	}
+	valid := pubKey.Verify(msg, sig)
+	c.Add(key, valid)
+	return valid
+}
+
+// Get returns the cached result for key and counts the hit or miss
+func (c *SigCache) Get(key Hash) (valid bool, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return false, false
+	}
+	c.hits++
+	c.ll.MoveToFront(e)
+	return e.Value.(*sigCacheEntry).valid, true
+}
+
+func (c *SigCache) Add(key Hash, valid bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.items[key]; ok {
+		e.Value.(*sigCacheEntry).valid = valid
+		c.ll.MoveToFront(e)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&sigCacheEntry{key, valid})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*sigCacheEntry).key)
+		c.evictions++
+	}
+}
+
+func (c *SigCache) Stats() SigCacheStats {
+	if c == nil {
+		return SigCacheStats{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return SigCacheStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       c.ll.Len(),
+	}
+}
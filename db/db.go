@@ -0,0 +1,311 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/metrics"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+var (
+	blocksBucket      = []byte("blocks")      // height -> serialized block
+	blockHashesBucket = []byte("blockHashes") // block hash -> height
+	txsBucket         = []byte("txs")         // tx hash -> height + index in block
+	balancesBucket    = []byte("balances")    // id -> balance + blocks until fee
+	historyBucket     = []byte("history")     // id + height + index -> direction + tx hash
+	metaBucket        = []byte("meta")
+
+	frozenEdgeKey  = []byte("frozenEdge")
+	balancelistKey = []byte("balancelist") // height, rollover fees, prev verifiers
+	historyEdgeKey = []byte("historyEdge")
+
+	allBuckets = [][]byte{blocksBucket, blockHashesBucket, txsBucket, balancesBucket, historyBucket, metaBucket}
+)
+
+var ErrNotFound = errors.New("not found")
+
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Store{db: db}
+	if height, err := s.FrozenEdgeHeight(); err == nil {
+		metrics.FrozenEdgeHeight.Set(float64(height))
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Freeze stores a newly frozen block together with the balance list at its
+// height, the block has to follow the frozen edge. Everything is written in
+// a single bolt transaction so the index never points at a half written
+// block. list may be nil if the balances should not be updated.
+func (s *Store) Freeze(bl *block.Block, list *balancelist.List) error {
+	if list != nil && list.Height != bl.Height {
+		return fmt.Errorf("balance list height %v does not match block height %v",
+			list.Height, bl.Height)
+	}
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		edge := meta.Get(frozenEdgeKey)
+		if edge != nil {
+			edgeHeight := int64(binary.BigEndian.Uint64(edge))
+			if bl.Height != edgeHeight+1 {
+				return fmt.Errorf("cannot freeze block %v on top of frozen edge %v",
+					bl.Height, edgeHeight)
+			}
+			edgeBlock, err := getBlock(tx, edge)
+			if err != nil {
+				return err
+			}
+			if bl.PrevBlockHash != edgeBlock.Hash() {
+				return fmt.Errorf("block %v does not follow the frozen edge, prev hash %v",
+					bl.Height, bl.PrevBlockHash.String())
+			}
+		}
+
+		height := heightKey(bl.Height)
+		if err := tx.Bucket(blocksBucket).Put(height, bl.Serialize()); err != nil {
+			return err
+		}
+
+		hash := bl.Hash()
+		if err := tx.Bucket(blockHashesBucket).Put(hash[:], height); err != nil {
+			return err
+		}
+
+		txs := tx.Bucket(txsBucket)
+		for i, t := range bl.Transactions {
+			txHash := t.Hash()
+			v := make([]byte, 12)
+			copy(v, height)
+			binary.BigEndian.PutUint32(v[8:], uint32(i))
+			if err := txs.Put(txHash[:], v); err != nil {
+				return err
+			}
+		}
+
+		// the history is only extended while it is complete, otherwise it
+		// has to be rebuilt
+		if bytes.Equal(meta.Get(historyEdgeKey), edge) {
+			if err := putHistory(tx, bl); err != nil {
+				return err
+			}
+		}
+
+		if list != nil {
+			if err := putBalancelist(tx, list); err != nil {
+				return err
+			}
+		}
+
+		return meta.Put(frozenEdgeKey, height)
+	})
+	if err != nil {
+		return err
+	}
+
+	metrics.FrozenEdgeHeight.Set(float64(bl.Height))
+	metrics.BlocksFrozen.Inc()
+	return nil
+}
+
+func putBalancelist(tx *bolt.Tx, list *balancelist.List) error {
+	// the balance list is a full snapshot, ids missing from it are gone
+	if err := tx.DeleteBucket(balancesBucket); err != nil {
+		return err
+	}
+	balances, err := tx.CreateBucket(balancesBucket)
+	if err != nil {
+		return err
+	}
+	for _, item := range list.Items {
+		if err := balances.Put(item.ID[:], encodeItem(item)); err != nil {
+			return err
+		}
+	}
+
+	header := make([]byte, 9, 9+len(list.PrevVerifiers)*32)
+	binary.BigEndian.PutUint64(header, uint64(list.Height))
+	header[8] = list.RolloverFees
+	for _, id := range list.PrevVerifiers {
+		header = append(header, id[:]...)
+	}
+
+	return tx.Bucket(metaBucket).Put(balancelistKey, header)
+}
+
+func (s *Store) FrozenEdgeHeight() (int64, error) {
+	var height int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		edge := tx.Bucket(metaBucket).Get(frozenEdgeKey)
+		if edge == nil {
+			return ErrNotFound
+		}
+		height = int64(binary.BigEndian.Uint64(edge))
+		return nil
+	})
+	return height, err
+}
+
+func (s *Store) FrozenEdge() (*block.Block, error) {
+	height, err := s.FrozenEdgeHeight()
+	if err != nil {
+		return nil, err
+	}
+	return s.Block(height)
+}
+
+func (s *Store) Block(height int64) (*block.Block, error) {
+	var bl *block.Block
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		bl, err = getBlock(tx, heightKey(height))
+		return err
+	})
+	return bl, err
+}
+
+func (s *Store) BlockByHash(hash crypto.Hash) (*block.Block, error) {
+	var bl *block.Block
+	err := s.db.View(func(tx *bolt.Tx) error {
+		height := tx.Bucket(blockHashesBucket).Get(hash[:])
+		if height == nil {
+			return ErrNotFound
+		}
+		var err error
+		bl, err = getBlock(tx, height)
+		return err
+	})
+	return bl, err
+}
+
+// Transaction returns a frozen tx and the height of the block it is in
+func (s *Store) Transaction(hash crypto.Hash) (*transaction.Tx, int64, error) {
+	var t *transaction.Tx
+	var height int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(txsBucket).Get(hash[:])
+		if v == nil {
+			return ErrNotFound
+		}
+		bl, err := getBlock(tx, v[:8])
+		if err != nil {
+			return err
+		}
+		i := int(binary.BigEndian.Uint32(v[8:]))
+		if i >= len(bl.Transactions) {
+			return fmt.Errorf("tx index %v out of range in block %v", i, bl.Height)
+		}
+		t, height = bl.Transactions[i], bl.Height
+		return nil
+	})
+	return t, height, err
+}
+
+// Balance returns the latest balance list item of an id
+func (s *Store) Balance(id crypto.PublicKey) (*balancelist.Item, error) {
+	var item *balancelist.Item
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(balancesBucket).Get(id[:])
+		if v == nil {
+			return ErrNotFound
+		}
+		item = decodeItem(id[:], v)
+		return nil
+	})
+	return item, err
+}
+
+// BalanceList rebuilds the latest balance list from the index
+func (s *Store) BalanceList() (*balancelist.List, error) {
+	list := balancelist.NewList()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		header := tx.Bucket(metaBucket).Get(balancelistKey)
+		if header == nil {
+			return ErrNotFound
+		}
+		list.Height = int64(binary.BigEndian.Uint64(header))
+		list.RolloverFees = header[8]
+		for i := 9; i+32 <= len(header); i += 32 {
+			id := crypto.PublicKey{}
+			copy(id[:], header[i:])
+			list.PrevVerifiers = append(list.PrevVerifiers, id)
+		}
+
+		return tx.Bucket(balancesBucket).ForEach(func(k, v []byte) error {
+			list.Items = append(list.Items, decodeItem(k, v))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func getBlock(tx *bolt.Tx, height []byte) (*block.Block, error) {
+	v := tx.Bucket(blocksBucket).Get(height)
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	bl := &block.Block{}
+	// v is only valid during the bolt tx, but Deserialize copies everything
+	if err := bl.DeserializeBlock(v); err != nil {
+		return nil, err
+	}
+	return bl, nil
+}
+
+// keys are big endian so the blocks bucket iterates in height order
+func heightKey(height int64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, uint64(height))
+	return k
+}
+
+func encodeItem(item *balancelist.Item) []byte {
+	v := make([]byte, 10)
+	binary.BigEndian.PutUint64(v, uint64(item.Balance))
+	binary.BigEndian.PutUint16(v[8:], uint16(item.BlocksUntilFee))
+	return v
+}
+
+func decodeItem(k, v []byte) *balancelist.Item {
+	item := &balancelist.Item{}
+	copy(item.ID[:], k)
+	item.Balance = int64(binary.BigEndian.Uint64(v))
+	item.BlocksUntilFee = int16(binary.BigEndian.Uint16(v[8:]))
+	return item
+}
@@ -0,0 +1,251 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+func openTestStore(t *testing.T) *Store {
+	dir, err := ioutil.TempDir("", "nyzodb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		s.Close()
+		os.RemoveAll(dir)
+	})
+	return s
+}
+
+func testBlock(height int64, prevHash crypto.Hash, txs ...*transaction.Tx) *block.Block {
+	bl := block.New(height, height*7000*1000*1000, prevHash, crypto.Hash{})
+	bl.Transactions = txs
+	bl.Sign(crypto.GenPrivKey())
+	return bl
+}
+
+func TestFreeze(t *testing.T) {
+	s := openTestStore(t)
+
+	if _, err := s.FrozenEdge(); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for empty store, got %v", err)
+	}
+
+	privKey := crypto.GenPrivKey()
+	recipient := crypto.PublicKey{0x01}
+	tx := transaction.NewStandard(10, recipient, []byte("test"))
+	tx.Sign(privKey)
+
+	bl0 := testBlock(0, crypto.Hash{})
+	bl1 := testBlock(1, bl0.Hash(), tx)
+	list := &balancelist.List{
+		Height:        1,
+		RolloverFees:  2,
+		PrevVerifiers: []crypto.PublicKey{bl0.VerifierID},
+		Items: []*balancelist.Item{
+			{ID: privKey.PubKey(), Balance: 90},
+			{ID: recipient, Balance: 10, BlocksUntilFee: 500},
+		},
+	}
+
+	if err := s.Freeze(bl0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Freeze(bl0, nil); err == nil {
+		t.Error("expected refreezing block 0 to fail")
+	}
+	if err := s.Freeze(bl1, &balancelist.List{Height: 5}); err == nil {
+		t.Error("expected freezing with a mismatched balance list to fail")
+	}
+	// a block from a fork has the right height but not the edge as parent
+	if err := s.Freeze(testBlock(1, crypto.Hash{0x01}), nil); err == nil {
+		t.Error("expected freezing a block from a fork to fail")
+	}
+	if err := s.Freeze(bl1, list); err != nil {
+		t.Fatal(err)
+	}
+
+	edge, err := s.FrozenEdge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edge.Height != 1 || edge.Hash() != bl1.Hash() {
+		t.Errorf("unexpected frozen edge %v", edge.Height)
+	}
+
+	byHash, err := s.BlockByHash(bl0.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if byHash.Height != 0 {
+		t.Errorf("expected block 0 by hash, got %v", byHash.Height)
+	}
+
+	gotTx, height, err := s.Transaction(tx.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 1 || gotTx.Hash() != tx.Hash() {
+		t.Errorf("unexpected tx at height %v", height)
+	}
+
+	item, err := s.Balance(recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Balance != 10 || item.BlocksUntilFee != 500 {
+		t.Errorf("unexpected balance item %+v", item)
+	}
+	if _, err := s.Balance(crypto.PublicKey{0x02}); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for unknown id, got %v", err)
+	}
+
+	gotList, err := s.BalanceList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotList.Height != 1 || gotList.RolloverFees != 2 ||
+		len(gotList.PrevVerifiers) != 1 || len(gotList.Items) != 2 {
+		t.Errorf("unexpected balance list %+v", gotList)
+	}
+}
+
+func TestBalancesReplaced(t *testing.T) {
+	s := openTestStore(t)
+
+	a, b := crypto.PublicKey{0x0a}, crypto.PublicKey{0x0b}
+	bl0 := testBlock(0, crypto.Hash{})
+	bl1 := testBlock(1, bl0.Hash())
+
+	s.Freeze(bl0, &balancelist.List{Items: []*balancelist.Item{{ID: a, Balance: 1}}})
+	s.Freeze(bl1, &balancelist.List{Height: 1, Items: []*balancelist.Item{{ID: b, Balance: 1}}})
+
+	if _, err := s.Balance(a); err != ErrNotFound {
+		t.Errorf("expected balance of removed id to be gone, got %v", err)
+	}
+	if _, err := s.Balance(b); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s := openTestStore(t)
+
+	a, b := crypto.GenPrivKey(), crypto.GenPrivKey()
+	coinGen := &transaction.Tx{Type: 0, Amount: 100, RecipientID: a.PubKey()}
+	toB := transaction.NewStandard(10, b.PubKey(), nil)
+	toB.Sign(a)
+	seed := &transaction.Tx{Type: 1, Amount: 5, RecipientID: a.PubKey()}
+	seed.Sign(a)
+	toA := transaction.NewStandard(3, a.PubKey(), nil)
+	toA.Sign(b)
+
+	bl0 := testBlock(0, crypto.Hash{}, coinGen)
+	bl1 := testBlock(1, bl0.Hash(), toB)
+	bl2 := testBlock(2, bl1.Hash())
+	bl3 := testBlock(3, bl2.Hash(), seed, toA)
+	for _, bl := range []*block.Block{bl0, bl1, bl2, bl3} {
+		if err := s.Freeze(bl, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry := func(height int64, index int, d Direction, tx *transaction.Tx) HistoryEntry {
+		return HistoryEntry{height, index, d, tx.Hash()}
+	}
+	tests := []struct {
+		id                   crypto.PublicKey
+		fromHeight, toHeight int64
+		offset, limit        int
+		entries              []HistoryEntry
+	}{
+		{a.PubKey(), 0, 3, 0, 0, []HistoryEntry{
+			entry(3, 1, Received, toA), entry(3, 0, SentToSelf, seed),
+			entry(1, 0, Sent, toB), entry(0, 0, Received, coinGen),
+		}},
+		{a.PubKey(), 0, 3, 1, 2, []HistoryEntry{
+			entry(3, 0, SentToSelf, seed), entry(1, 0, Sent, toB),
+		}},
+		{a.PubKey(), 1, 2, 0, 0, []HistoryEntry{entry(1, 0, Sent, toB)}},
+		{a.PubKey(), 0, 100, 3, 10, []HistoryEntry{entry(0, 0, Received, coinGen)}},
+		{b.PubKey(), 0, 3, 0, 0, []HistoryEntry{entry(3, 1, Sent, toA), entry(1, 0, Received, toB)}},
+		{crypto.PublicKey{0x01}, 0, 3, 0, 0, []HistoryEntry{}},
+	}
+
+	check := func() {
+		for i, test := range tests {
+			entries, err := s.History(test.id, test.fromHeight, test.toHeight, test.offset, test.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []HistoryEntry{}
+			for _, e := range entries {
+				got = append(got, *e)
+			}
+			if !reflect.DeepEqual(got, test.entries) {
+				t.Errorf("unexpected history (%v)\nexpected %+v\ngot      %+v", i, test.entries, got)
+			}
+		}
+	}
+	check()
+
+	if err := s.RebuildHistory(); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if height, err := s.HistoryEdgeHeight(); err != nil || height != 3 {
+		t.Errorf("expected history edge 3, got %v %v", height, err)
+	}
+}
+
+func TestHistoryBehind(t *testing.T) {
+	s := openTestStore(t)
+
+	privKey := crypto.GenPrivKey()
+	tx := transaction.NewStandard(10, crypto.PublicKey{0x01}, nil)
+	tx.Sign(privKey)
+
+	bl0 := testBlock(0, crypto.Hash{})
+	if err := s.Freeze(bl0, nil); err != nil {
+		t.Fatal(err)
+	}
+	// like a store from before the history index
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Delete(historyEdgeKey)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bl1 := testBlock(1, bl0.Hash(), tx)
+	if err := s.Freeze(bl1, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.HistoryEdgeHeight(); err != ErrNotFound {
+		t.Errorf("expected the history to stay behind, got %v", err)
+	}
+	if entries, _ := s.History(privKey.PubKey(), 0, 1, 0, 0); len(entries) != 0 {
+		t.Errorf("expected no history before rebuilding, got %v", len(entries))
+	}
+
+	if err := s.RebuildHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := s.History(privKey.PubKey(), 0, 1, 0, 0); len(entries) != 1 {
+		t.Errorf("expected 1 history entry after rebuilding, got %v", len(entries))
+	}
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+// rebuildBatch is the number of blocks indexed per bolt tx by RebuildHistory
+const rebuildBatch = 1000
+
+// Direction is how a tx involves an id
+type Direction byte
+
+const (
+	Received Direction = 1
+	Sent     Direction = 2
+	// SentToSelf is a seed tx, the sender is the recipient
+	SentToSelf = Received | Sent
+)
+
+func (d Direction) String() string {
+	switch d {
+	case Received:
+		return "in"
+	case Sent:
+		return "out"
+	case SentToSelf:
+		return "self"
+	}
+	return fmt.Sprintf("Direction(%d)", byte(d))
+}
+
+func (d Direction) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Direction) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "in":
+		*d = Received
+	case "out":
+		*d = Sent
+	case "self":
+		*d = SentToSelf
+	default:
+		return fmt.Errorf("unknown direction %q", b)
+	}
+	return nil
+}
+
+type HistoryEntry struct {
+	Height int64 `json:"height"`
+	// Index is the position of the tx in its block
+	Index     int         `json:"index"`
+	Direction Direction   `json:"direction"`
+	TxHash    crypto.Hash `json:"txHash"`
+}
+
+// history keys are id + height + index in block so the txes of an id are
+// next to each other in height order, values are direction + tx hash
+func historyKey(id crypto.PublicKey, height int64, index int) []byte {
+	k := make([]byte, 44)
+	copy(k, id[:])
+	binary.BigEndian.PutUint64(k[32:], uint64(height))
+	binary.BigEndian.PutUint32(k[40:], uint32(index))
+	return k
+}
+
+// putHistory indexes the txes of bl under their sender and recipient
+func putHistory(tx *bolt.Tx, bl *block.Block) error {
+	history := tx.Bucket(historyBucket)
+	for i, t := range bl.Transactions {
+		directions := map[crypto.PublicKey]Direction{t.RecipientID: Received}
+		// coingeneration txes have no sender
+		if t.Type != 0 {
+			directions[t.SenderID] |= Sent
+		}
+
+		hash := t.Hash()
+		for id, d := range directions {
+			v := append([]byte{byte(d)}, hash[:]...)
+			if err := history.Put(historyKey(id, bl.Height, i), v); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Bucket(metaBucket).Put(historyEdgeKey, heightKey(bl.Height))
+}
+
+// HistoryEdgeHeight is the height up to which the history is indexed, it
+// is behind the frozen edge if the index needs to be rebuilt
+func (s *Store) HistoryEdgeHeight() (int64, error) {
+	var height int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		edge := tx.Bucket(metaBucket).Get(historyEdgeKey)
+		if edge == nil {
+			return ErrNotFound
+		}
+		height = int64(binary.BigEndian.Uint64(edge))
+		return nil
+	})
+	return height, err
+}
+
+// History returns the txes involving id between fromHeight and toHeight,
+// both inclusive, newest first. offset entries are skipped and at most
+// limit are returned, limit <= 0 returns all of them.
+func (s *Store) History(id crypto.PublicKey, fromHeight, toHeight int64, offset, limit int) ([]*HistoryEntry, error) {
+	entries := []*HistoryEntry{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyBucket).Cursor()
+
+		// seek past the last possible key at toHeight and go back from there
+		seek := append(historyKey(id, toHeight, 0)[:40], 0xff, 0xff, 0xff, 0xff, 0xff)
+		k, v := c.Seek(seek)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+
+		skipped := 0
+		for ; k != nil && bytes.HasPrefix(k, id[:]); k, v = c.Prev() {
+			height := int64(binary.BigEndian.Uint64(k[32:]))
+			if height > toHeight {
+				continue
+			}
+			if height < fromHeight {
+				break
+			}
+			if skipped < offset {
+				skipped++
+				continue
+			}
+
+			e := &HistoryEntry{
+				Height:    height,
+				Index:     int(binary.BigEndian.Uint32(k[40:])),
+				Direction: Direction(v[0]),
+			}
+			copy(e.TxHash[:], v[1:])
+			entries = append(entries, e)
+			if limit > 0 && len(entries) == limit {
+				break
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// RebuildHistory drops the history index and indexes every stored block
+// again, in batches so a long chain doesn't end up in a single bolt tx
+func (s *Store) RebuildHistory() error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(historyBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(historyBucket); err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Delete(historyEdgeKey)
+	})
+	if err != nil {
+		return err
+	}
+
+	var next []byte
+	for {
+		done := false
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			c := tx.Bucket(blocksBucket).Cursor()
+			k, v := c.First()
+			if next != nil {
+				k, v = c.Seek(next)
+			}
+			for i := 0; k != nil && i < rebuildBatch; i++ {
+				bl := &block.Block{}
+				if err := bl.DeserializeBlock(v); err != nil {
+					return fmt.Errorf("block %x: %v", k, err)
+				}
+				if err := putHistory(tx, bl); err != nil {
+					return err
+				}
+				k, v = c.Next()
+			}
+			if k == nil {
+				done = true
+			} else {
+				next = append([]byte{}, k...)
+			}
+			return nil
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package explorer
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/db"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+const (
+	// recentBlocks are shown on the index page
+	recentBlocks = 10
+	// historyBlocks is how far back the history of an id is searched
+	// without a History index
+	historyBlocks = 1000
+	// historyPageSize is the number of txes per page with a History index
+	historyPageSize = 50
+	// maxCycleLen stops the cycle search if no verifier repeats
+	maxCycleLen = 10000
+)
+
+type Chain interface {
+	Block(height int64) (*block.Block, error)
+	BlockByHash(hash crypto.Hash) (*block.Block, error)
+	FrozenEdge() (*block.Block, error)
+	// Transaction returns a frozen tx and the height of its block
+	Transaction(hash crypto.Hash) (*transaction.Tx, int64, error)
+}
+
+type Balances interface {
+	Balance(id crypto.PublicKey) (*balancelist.Item, error)
+}
+
+// History is an index of the txes of an id, newest first
+type History interface {
+	History(id crypto.PublicKey, fromHeight, toHeight int64, offset, limit int) ([]*db.HistoryEntry, error)
+}
+
+// Server serves the explorer pages, every page is also available as json
+// under /api, e.g. /api/block/10
+type Server struct {
+	Chain    Chain
+	Balances Balances
+	// History is optional, without it only the recent blocks are searched
+	History History
+
+	pages map[string]page
+}
+
+type page struct {
+	data func(arg string, q url.Values) (interface{}, error)
+	tmpl *template.Template
+}
+
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string {
+	return e.msg
+}
+
+func NewServer(chain Chain, balances Balances, history History) *Server {
+	s := &Server{
+		Chain:    chain,
+		Balances: balances,
+		History:  history,
+	}
+	s.pages = map[string]page{
+		"":      {s.index, parse("index", indexTmpl)},
+		"block": {s.block, parse("block", blockTmpl)},
+		"tx":    {s.tx, parse("tx", txTmpl)},
+		"id":    {s.id, parse("id", idTmpl)},
+		"cycle": {s.cycle, parse("cycle", cycleTmpl)},
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := r.URL.Path
+	api := strings.HasPrefix(path, "/api/")
+	if api {
+		path = strings.TrimPrefix(path, "/api")
+	}
+	name, arg := strings.TrimPrefix(path, "/"), ""
+	if i := strings.Index(name, "/"); i >= 0 {
+		name, arg = name[:i], name[i+1:]
+	}
+
+	if name == "search" && !api {
+		s.search(w, r)
+		return
+	}
+
+	p, ok := s.pages[name]
+	if !ok {
+		s.writeError(w, api, &httpError{http.StatusNotFound, "page not found"})
+		return
+	}
+	data, err := p.data(arg, r.URL.Query())
+	if err != nil {
+		s.writeError(w, api, err)
+		return
+	}
+
+	if api {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(data)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := p.tmpl.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, api bool, err error) {
+	code := http.StatusInternalServerError
+	if e, ok := err.(*httpError); ok {
+		code = e.code
+	} else if errors.Is(err, db.ErrNotFound) {
+		code = http.StatusNotFound
+	}
+
+	if api {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	errorTmpl.Execute(w, err.Error())
+}
+
+// search redirects to the page of a height, hash or id
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	target := "/id/" + q
+	if _, err := strconv.ParseInt(q, 10, 64); err == nil {
+		target = "/block/" + q
+	} else if hash, err := crypto.ParseHash(q); err == nil {
+		if _, err := s.Chain.BlockByHash(hash); err == nil {
+			target = "/block/" + q
+		} else if _, _, err := s.Chain.Transaction(hash); err == nil {
+			target = "/tx/" + q
+		}
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+type indexPage struct {
+	FrozenEdge *blockPage   `json:"frozenEdge"`
+	Blocks     []*blockPage `json:"blocks"`
+}
+
+func (s *Server) index(arg string, q url.Values) (interface{}, error) {
+	if arg != "" {
+		return nil, &httpError{http.StatusNotFound, "page not found"}
+	}
+	edge, err := s.Chain.FrozenEdge()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &indexPage{FrozenEdge: newBlockPage(edge)}
+	for height := edge.Height; height >= 0 && height > edge.Height-recentBlocks; height-- {
+		bl, err := s.Chain.Block(height)
+		if err != nil {
+			return nil, err
+		}
+		p.Blocks = append(p.Blocks, newBlockPage(bl))
+	}
+	return p, nil
+}
+
+type blockPage struct {
+	Hash crypto.Hash `json:"hash"`
+	*block.Block
+}
+
+func newBlockPage(bl *block.Block) *blockPage {
+	return &blockPage{bl.Hash(), bl}
+}
+
+func (p *blockPage) NextHeight() int64 {
+	return p.Height + 1
+}
+
+func (p *blockPage) TxRows() []*txPage {
+	rows := []*txPage{}
+	for _, tx := range p.Transactions {
+		rows = append(rows, &txPage{p.Height, tx.Hash(), tx})
+	}
+	return rows
+}
+
+// block takes a height or a block hash
+func (s *Server) block(arg string, q url.Values) (interface{}, error) {
+	var bl *block.Block
+	var err error
+	if height, perr := strconv.ParseInt(arg, 10, 64); perr == nil {
+		bl, err = s.Chain.Block(height)
+	} else if hash, perr := crypto.ParseHash(arg); perr == nil {
+		bl, err = s.Chain.BlockByHash(hash)
+	} else {
+		return nil, &httpError{http.StatusBadRequest, "invalid height or hash: " + arg}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newBlockPage(bl), nil
+}
+
+type txPage struct {
+	Height int64       `json:"height"`
+	Hash   crypto.Hash `json:"hash"`
+	*transaction.Tx
+}
+
+func (s *Server) tx(arg string, q url.Values) (interface{}, error) {
+	hash, err := crypto.ParseHash(arg)
+	if err != nil {
+		return nil, &httpError{http.StatusBadRequest, "invalid hash: " + arg}
+	}
+	tx, height, err := s.Chain.Transaction(hash)
+	if err != nil {
+		return nil, err
+	}
+	return &txPage{height, hash, tx}, nil
+}
+
+type idPage struct {
+	ID         crypto.PublicKey  `json:"id"`
+	NyzoString string            `json:"nyzoString"`
+	Balance    *balancelist.Item `json:"balance"`
+	// FromHeight is the first height searched for Transactions
+	FromHeight int64 `json:"fromHeight"`
+	// Page is set with a History index, the txes are paged from the newest
+	Page int `json:"page"`
+	// More is set if there are older txes on the next page
+	More         bool         `json:"more"`
+	Transactions []*historyTx `json:"transactions"`
+}
+
+type historyTx struct {
+	Height    int64        `json:"height"`
+	Hash      crypto.Hash  `json:"hash"`
+	Direction db.Direction `json:"direction"`
+	*transaction.Tx
+}
+
+// id takes a hex or nyzo string id, the history page is set with ?page=
+func (s *Server) id(arg string, q url.Values) (interface{}, error) {
+	id, err := crypto.ParseID(arg)
+	if err != nil {
+		return nil, &httpError{http.StatusBadRequest, "invalid id: " + arg}
+	}
+
+	p := &idPage{ID: id, NyzoString: id.NyzoString(), Transactions: []*historyTx{}}
+	p.Balance, err = s.Balances.Balance(id)
+	if errors.Is(err, db.ErrNotFound) {
+		p.Balance = &balancelist.Item{ID: id}
+	} else if err != nil {
+		return nil, err
+	}
+
+	edge, err := s.Chain.FrozenEdge()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.History != nil {
+		if page := q.Get("page"); page != "" {
+			if p.Page, err = strconv.Atoi(page); err != nil || p.Page < 0 {
+				return nil, &httpError{http.StatusBadRequest, "invalid page: " + page}
+			}
+		}
+		// one more than a page to know if there is a next one
+		entries, err := s.History.History(id, 0, edge.Height, p.Page*historyPageSize, historyPageSize+1)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > historyPageSize {
+			p.More = true
+			entries = entries[:historyPageSize]
+		}
+		for _, e := range entries {
+			tx, _, err := s.Chain.Transaction(e.TxHash)
+			if err != nil {
+				return nil, err
+			}
+			p.Transactions = append(p.Transactions, &historyTx{e.Height, e.TxHash, e.Direction, tx})
+		}
+		return p, nil
+	}
+
+	p.FromHeight = edge.Height - historyBlocks + 1
+	if p.FromHeight < 0 {
+		p.FromHeight = 0
+	}
+	for height := edge.Height; height >= p.FromHeight; height-- {
+		bl, err := s.Chain.Block(height)
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range bl.Transactions {
+			var d db.Direction
+			if tx.RecipientID == id {
+				d |= db.Received
+			}
+			if tx.Type != 0 && tx.SenderID == id {
+				d |= db.Sent
+			}
+			if d != 0 {
+				p.Transactions = append(p.Transactions, &historyTx{height, tx.Hash(), d, tx})
+			}
+		}
+	}
+	return p, nil
+}
+
+func (p *idPage) PrevPage() int {
+	return p.Page - 1
+}
+
+func (p *idPage) NextPage() int {
+	return p.Page + 1
+}
+
+type cyclePage struct {
+	FrozenEdgeHeight int64 `json:"frozenEdgeHeight"`
+	// Complete is false if the start of the cycle was not found
+	Complete  bool               `json:"complete"`
+	Verifiers []crypto.PublicKey `json:"verifiers"`
+}
+
+// cycle walks back from the frozen edge until a verifier repeats, the
+// verifiers are ordered from the oldest block
+func (s *Server) cycle(arg string, q url.Values) (interface{}, error) {
+	bl, err := s.Chain.FrozenEdge()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cyclePage{FrozenEdgeHeight: bl.Height}
+	seen := map[crypto.PublicKey]bool{}
+	for len(p.Verifiers) < maxCycleLen {
+		if seen[bl.VerifierID] {
+			p.Complete = true
+			break
+		}
+		seen[bl.VerifierID] = true
+		p.Verifiers = append(p.Verifiers, bl.VerifierID)
+
+		if bl.Height == 0 {
+			p.Complete = true
+			break
+		}
+		if bl, err = s.Chain.Block(bl.Height - 1); err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				break
+			}
+			return nil, err
+		}
+	}
+
+	for i, j := 0, len(p.Verifiers)-1; i < j; i, j = i+1, j-1 {
+		p.Verifiers[i], p.Verifiers[j] = p.Verifiers[j], p.Verifiers[i]
+	}
+	return p, nil
+}
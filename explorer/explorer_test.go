@@ -0,0 +1,163 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/db"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+type testChain struct {
+	store    *db.Store
+	sender   crypto.PrivateKey
+	verifier []crypto.PrivateKey
+	tx       *transaction.Tx
+}
+
+// newTestChain freezes 4 blocks from the verifiers a, b, c, a with a tx in
+// block 2
+func newTestChain(t *testing.T) *testChain {
+	dir, err := ioutil.TempDir("", "nyzoexplorer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := db.Open(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Close()
+		os.RemoveAll(dir)
+	})
+
+	c := &testChain{store: store, sender: crypto.GenPrivKey()}
+	for i := 0; i < 3; i++ {
+		c.verifier = append(c.verifier, crypto.GenPrivKey())
+	}
+	c.tx = transaction.NewStandard(10, crypto.PublicKey{0x01}, []byte("explorer"))
+	c.tx.Sign(c.sender)
+
+	prevHash := crypto.Hash{}
+	for height, v := range []int{0, 1, 2, 0} {
+		bl := block.New(int64(height), int64(height)*7000*1000*1000, prevHash, crypto.Hash{})
+		if height == 2 {
+			bl.Transactions = []*transaction.Tx{c.tx}
+		}
+		bl.Sign(c.verifier[v])
+		list := &balancelist.List{
+			Height: int64(height),
+			Items:  []*balancelist.Item{{ID: c.sender.PubKey(), Balance: 90}},
+		}
+		if err := store.Freeze(bl, list); err != nil {
+			t.Fatal(err)
+		}
+		prevHash = bl.Hash()
+	}
+	return c
+}
+
+func get(t *testing.T, srv http.Handler, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	return rec
+}
+
+func TestPages(t *testing.T) {
+	c := newTestChain(t)
+
+	bl2, _ := c.store.Block(2)
+	bl2Hash := bl2.Hash()
+	txHash := c.tx.Hash()
+	senderID := c.sender.PubKey()
+
+	tests := []struct {
+		path     string
+		code     int
+		contains string
+	}{
+		{"/", http.StatusOK, "frozen edge 3"},
+		{"/block/2", http.StatusOK, "1 transactions"},
+		{"/block/" + hex.EncodeToString(bl2Hash[:]), http.StatusOK, "block 2"},
+		{"/block/9", http.StatusNotFound, "not found"},
+		{"/block/xyz", http.StatusBadRequest, "invalid height or hash"},
+		{"/tx/" + hex.EncodeToString(txHash[:]), http.StatusOK, "∩0.000010"},
+		{"/tx/00", http.StatusBadRequest, "invalid hash"},
+		{"/id/" + senderID.NyzoString(), http.StatusOK, "∩0.000090"},
+		{"/id/" + senderID.String(), http.StatusOK, "explorer"},
+		{"/cycle", http.StatusOK, "3 verifiers"},
+		{"/nothing", http.StatusNotFound, "page not found"},
+		{"/api/block/2", http.StatusOK, `"hash":"` + hex.EncodeToString(bl2Hash[:]) + `"`},
+		{"/api/block/9", http.StatusNotFound, `"error"`},
+		{"/api/id/" + senderID.String(), http.StatusOK, `"direction":"out"`},
+		{"/id/" + senderID.String() + "?page=x", http.StatusBadRequest, "invalid page"},
+		{"/api/id/" + senderID.String() + "?page=1", http.StatusOK, `"transactions":[]`},
+	}
+
+	// with the history index and by searching the recent blocks
+	for _, srv := range []*Server{NewServer(c.store, c.store, c.store), NewServer(c.store, c.store, nil)} {
+		for i, test := range tests {
+			if srv.History == nil && strings.Contains(test.path, "page=") {
+				continue
+			}
+			rec := get(t, srv, test.path)
+			if rec.Code != test.code {
+				t.Errorf("expected status %v, got %v (%v)", test.code, rec.Code, i)
+			}
+			if !strings.Contains(rec.Body.String(), test.contains) {
+				t.Errorf("expected %q in %v (%v)", test.contains, test.path, i)
+			}
+		}
+	}
+}
+
+func TestAPI(t *testing.T) {
+	c := newTestChain(t)
+	srv := NewServer(c.store, c.store, c.store)
+
+	// the json reuses the tags of the wire types
+	txHash := c.tx.Hash()
+	p := struct {
+		Height int64       `json:"height"`
+		Hash   crypto.Hash `json:"hash"`
+		transaction.Tx
+	}{}
+	rec := get(t, srv, "/api/tx/"+hex.EncodeToString(txHash[:]))
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Height != 2 || p.Hash != txHash || p.SenderID != c.sender.PubKey() || p.Amount != 10 {
+		t.Errorf("unexpected tx %+v", p)
+	}
+
+	cycle := &cyclePage{}
+	rec = get(t, srv, "/api/cycle")
+	if err := json.Unmarshal(rec.Body.Bytes(), cycle); err != nil {
+		t.Fatal(err)
+	}
+	// block 3 is verified by the verifier of block 0 again
+	expected := []crypto.PublicKey{c.verifier[1].PubKey(), c.verifier[2].PubKey(), c.verifier[0].PubKey()}
+	if !cycle.Complete || len(cycle.Verifiers) != 3 {
+		t.Fatalf("unexpected cycle %+v", cycle)
+	}
+	for i := range expected {
+		if cycle.Verifiers[i] != expected[i] {
+			t.Errorf("unexpected cycle verifier (%v)", i)
+		}
+	}
+
+	rec = get(t, srv, "/search?q="+hex.EncodeToString(txHash[:]))
+	if loc := rec.Header().Get("Location"); loc != "/tx/"+hex.EncodeToString(txHash[:]) {
+		t.Errorf("unexpected search redirect %q", loc)
+	}
+}
@@ -0,0 +1,136 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+var funcs = template.FuncMap{
+	"amount": func(micros int64) string {
+		sign := ""
+		if micros < 0 {
+			sign, micros = "-", -micros
+		}
+		return fmt.Sprintf("%v∩%d.%06d", sign, micros/1000000, micros%1000000)
+	},
+	"hash": func(h crypto.Hash) string {
+		return hex.EncodeToString(h[:])
+	},
+	"shortHash": func(h crypto.Hash) string {
+		return hex.EncodeToString(h[:4]) + "..." + hex.EncodeToString(h[28:])
+	},
+	"id": func(id crypto.PublicKey) string {
+		return id.NyzoString()
+	},
+	"shortID": func(id crypto.PublicKey) string {
+		return id.StringCompact()
+	},
+	"time": func(nanos int64) string {
+		return time.Unix(0, nanos).UTC().Format("2006-01-02 15:04:05.000 MST")
+	},
+	"senderData": transaction.SenderDataString,
+}
+
+func parse(name, body string) *template.Template {
+	t := template.Must(template.New(name).Funcs(funcs).Parse(layoutTmpl))
+	return template.Must(t.Parse(body))
+}
+
+var errorTmpl = parse("error", `{{define "title"}}error{{end}}
+{{define "content"}}<p>{{.}}</p>{{end}}`)
+
+const layoutTmpl = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>nyzo explorer - {{template "title" .}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+.mono { font-family: monospace; }
+</style>
+</head>
+<body>
+<p><a href="/">home</a> | <a href="/cycle">cycle</a>
+<form action="/search" style="display: inline"><input name="q" size="70" placeholder="height, hash or id"></form></p>
+{{template "content" .}}
+</body>
+</html>
+`
+
+const blockRowsTmpl = `{{define "blockRows"}}<table>
+<tr><th>height</th><th>hash</th><th>verifier</th><th>txes</th><th>verified</th></tr>
+{{range .}}<tr><td><a href="/block/{{.Height}}">{{.Height}}</a></td>
+<td class="mono">{{hash .Hash}}</td>
+<td class="mono"><a href="/id/{{id .VerifierID}}">{{shortID .VerifierID}}</a></td>
+<td>{{len .Transactions}}</td><td>{{time .VerificationTimestamp}}</td></tr>
+{{end}}</table>{{end}}`
+
+const txRowsTmpl = `{{define "txRows"}}<table>
+<tr><th>hash</th><th>type</th><th>amount</th><th>from</th><th>to</th><th>sender data</th></tr>
+{{range .}}<tr><td class="mono"><a href="/tx/{{hash .Hash}}">{{shortHash .Hash}}</a></td>
+<td>{{.Type}}</td><td>{{amount .Amount}}</td>
+<td class="mono">{{if .Type}}<a href="/id/{{id .SenderID}}">{{shortID .SenderID}}</a>{{end}}</td>
+<td class="mono"><a href="/id/{{id .RecipientID}}">{{shortID .RecipientID}}</a></td>
+<td>{{senderData .SenderData}}</td></tr>
+{{end}}</table>{{end}}`
+
+const indexTmpl = `{{define "title"}}frozen edge {{.FrozenEdge.Height}}{{end}}
+{{define "content"}}<h2>frozen edge {{.FrozenEdge.Height}}</h2>
+{{template "blockRows" .Blocks}}{{end}}` + blockRowsTmpl
+
+const blockTmpl = `{{define "title"}}block {{.Height}}{{end}}
+{{define "content"}}<h2>block {{.Height}}</h2>
+<table>
+<tr><td>hash</td><td class="mono">{{hash .Hash}}</td></tr>
+<tr><td>previous block</td><td class="mono">{{if .Height}}<a href="/block/{{.PrevHeight}}">{{hash .PrevBlockHash}}</a>{{end}}</td></tr>
+<tr><td>start</td><td>{{time .StartTimestamp}}</td></tr>
+<tr><td>verified</td><td>{{time .VerificationTimestamp}}</td></tr>
+<tr><td>balance list hash</td><td class="mono">{{hash .BalancelistHash}}</td></tr>
+<tr><td>verifier</td><td class="mono"><a href="/id/{{id .VerifierID}}">{{id .VerifierID}}</a></td></tr>
+</table>
+<p>{{if .Height}}<a href="/block/{{.PrevHeight}}">previous</a> | {{end}}<a href="/block/{{.NextHeight}}">next</a></p>
+<h3>{{len .Transactions}} transactions</h3>
+{{template "txRows" .TxRows}}{{end}}` + txRowsTmpl
+
+const txTmpl = `{{define "title"}}transaction {{hash .Hash}}{{end}}
+{{define "content"}}<h2>transaction</h2>
+<table>
+<tr><td>hash</td><td class="mono">{{hash .Hash}}</td></tr>
+<tr><td>block</td><td><a href="/block/{{.Height}}">{{.Height}}</a></td></tr>
+<tr><td>type</td><td>{{.Type}}</td></tr>
+<tr><td>time</td><td>{{time .Timestamp}}</td></tr>
+<tr><td>amount</td><td>{{amount .Amount}}</td></tr>
+{{if .Type}}<tr><td>from</td><td class="mono"><a href="/id/{{id .SenderID}}">{{id .SenderID}}</a></td></tr>{{end}}
+<tr><td>to</td><td class="mono"><a href="/id/{{id .RecipientID}}">{{id .RecipientID}}</a></td></tr>
+<tr><td>sender data</td><td>{{senderData .SenderData}}</td></tr>
+</table>{{end}}`
+
+const idTmpl = `{{define "title"}}{{.NyzoString}}{{end}}
+{{define "content"}}<h2 class="mono">{{.NyzoString}}</h2>
+<table>
+<tr><td>id</td><td class="mono">{{.ID.StringWithDashes}}</td></tr>
+<tr><td>balance</td><td>{{amount .Balance.Balance}}</td></tr>
+<tr><td>blocks until fee</td><td>{{.Balance.BlocksUntilFee}}</td></tr>
+</table>
+<h3>transactions since block {{.FromHeight}}</h3>
+<table>
+<tr><th>block</th><th>hash</th><th>direction</th><th>amount</th><th>sender data</th></tr>
+{{range .Transactions}}<tr><td><a href="/block/{{.Height}}">{{.Height}}</a></td>
+<td class="mono"><a href="/tx/{{hash .Hash}}">{{shortHash .Hash}}</a></td>
+<td>{{.Direction}}</td><td>{{amount .Amount}}</td><td>{{senderData .SenderData}}</td></tr>
+{{end}}</table>
+<p>{{if .Page}}<a href="?page={{.PrevPage}}">newer</a>{{end}}
+{{if .More}}<a href="?page={{.NextPage}}">older</a>{{end}}</p>{{end}}`
+
+const cycleTmpl = `{{define "title"}}cycle{{end}}
+{{define "content"}}<h2>cycle at block {{.FrozenEdgeHeight}}</h2>
+<p>{{len .Verifiers}} verifiers{{if not .Complete}}, the start of the cycle was not found{{end}}</p>
+<ol>{{range .Verifiers}}<li class="mono"><a href="/id/{{id .}}">{{id .}}</a></li>
+{{end}}</ol>{{end}}`
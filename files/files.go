@@ -1,6 +1,7 @@
 package files
 
 import (
+	"errors"
 	"io"
 	"io/ioutil"
 	"os"
@@ -9,12 +10,42 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/logging"
+	"github.com/qqvv/go-nyzo/metrics"
 )
 
+var log = logging.Logger("files")
+
 var nyzoPath = AppDataDir()
 
 var mu sync.RWMutex
 
+// set by Shutdown, writes fail afterwards
+var closed bool
+
+var ErrClosed = errors.New("files: shut down")
+
+// SetDataDir overrides the AppDataDir for all file operations
+func SetDataDir(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return err
+	}
+	nyzoPath = path
+	return nil
+}
+
+func DataDir() string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return nyzoPath
+}
+
 func AppDataDir() string {
 	var homeDir string
 	usr, err := user.Current()
@@ -69,6 +100,10 @@ func Delete(filename string) error {
 	mu.Lock()
 	defer mu.Unlock()
 
+	if closed {
+		return ErrClosed
+	}
+
 	// check if file exists first
 	if _, err := os.Stat(filepath.Join(nyzoPath, filename)); err != nil {
 		return err
@@ -83,28 +118,113 @@ func Write(filename string, data []byte) error {
 	mu.Lock()
 	defer mu.Unlock()
 
+	return write(filename, data, 0666, false)
+}
+
+// WriteMode is like Write but sets the permissions of the file to perm
+func WriteMode(filename string, data []byte, perm os.FileMode) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return write(filename, data, perm, true)
+}
+
+// Create is like WriteMode but fails with an error satisfying os.IsExist
+// if the file already exists
+func Create(filename string, data []byte, perm os.FileMode) (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if closed {
+		log.Warn("write after shutdown", "file", filename)
+		return ErrClosed
+	}
+	defer func(start time.Time) {
+		elapsed := time.Since(start)
+		metrics.FileWriteSeconds.Observe(elapsed.Seconds())
+		if err != nil && !os.IsExist(err) {
+			log.Error("create failed", "file", filename, "err", err)
+			return
+		}
+		if err == nil {
+			log.Debug("created file", "file", filename, "bytes", len(data), "elapsed", elapsed)
+		}
+	}(time.Now())
+
+	// the data is written to a temporary file of our own first, linking it
+	// fails if the file exists, even if another process just created it
+	f, err := ioutil.TempFile(nyzoPath, filename+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err = writeFile(f, data, perm, true); err != nil {
+		return err
+	}
+	return os.Link(f.Name(), filepath.Join(nyzoPath, filename))
+}
+
+// Shutdown waits for in-flight writes and makes all further writes fail
+func Shutdown() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	closed = true
+	log.Info("shut down")
+}
+
+// write leaves perm subject to the umask unless exact is set
+func write(filename string, data []byte, perm os.FileMode, exact bool) (err error) {
+	if closed {
+		log.Warn("write after shutdown", "file", filename)
+		return ErrClosed
+	}
+	defer func(start time.Time) {
+		elapsed := time.Since(start)
+		metrics.FileWriteSeconds.Observe(elapsed.Seconds())
+		if err != nil {
+			log.Error("write failed", "file", filename, "err", err)
+			return
+		}
+		log.Debug("wrote file", "file", filename, "bytes", len(data), "elapsed", elapsed)
+	}(time.Now())
+
 	// first create a temporary file
-	f, err := os.Create(filepath.Join(nyzoPath, filename+".tmp"))
+	tmpPath := filepath.Join(nyzoPath, filename+".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return err
 	}
 
+	if err = writeFile(f, data, perm, exact); err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, filepath.Join(nyzoPath, filename))
+	return err
+}
+
+// writeFile writes data to f and closes it
+func writeFile(f *os.File, data []byte, perm os.FileMode, exact bool) error {
 	n, err := f.Write(data)
 	if err != nil {
+		f.Close()
 		return err
 	}
 	if n < len(data) {
+		f.Close()
 		return io.ErrShortWrite
 	}
-	if err = f.Close(); err != nil {
-		return err
+	// the tmp file may have existed with other permissions, and the
+	// umask applies on creation
+	if exact {
+		if err = f.Chmod(perm); err != nil {
+			f.Close()
+			return err
+		}
 	}
-
-	err = os.Rename(
-		filepath.Join(nyzoPath, filename+".tmp"),
-		filepath.Join(nyzoPath, filename),
-	)
-	return err
+	return f.Close()
 }
 
 func ReadString(filename string) (string, error) {
@@ -130,7 +250,21 @@ func ReadBytes(filename string) ([]byte, error) {
 	return b, nil
 }
 
+func Mode(filename string) (os.FileMode, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	fi, err := os.Stat(filepath.Join(nyzoPath, filename))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Mode(), nil
+}
+
 func Exists(filename string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
 	if _, err := os.Stat(filepath.Join(nyzoPath, filename)); err != nil {
 		if os.IsNotExist(err) {
 			return false
@@ -3,6 +3,9 @@ package files
 import (
 	"bytes"
 	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -34,3 +37,48 @@ func TestReadWriteDelete(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestCreate(t *testing.T) {
+	fn := "testfile_create"
+	if err := Create(fn, []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer Delete(fn)
+
+	if err := Create(fn, []byte("b"), 0600); !os.IsExist(err) {
+		t.Errorf("expected os.IsExist error, got %v", err)
+	}
+
+	s, err := ReadString(fn)
+	if err != nil {
+		t.Error(err)
+	}
+	if s != "a" {
+		t.Error(errors.New("existing file was overwritten"))
+	}
+
+	mode, err := Mode(fn)
+	if err != nil {
+		t.Error(err)
+	}
+	if runtime.GOOS != "windows" && mode.Perm() != 0600 {
+		t.Errorf("expected permissions 0600, got %v", mode.Perm())
+	}
+}
+
+func TestCreateNoTmpLeft(t *testing.T) {
+	fn := "testfile_create_tmp"
+	if err := Create(fn, []byte("a"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer Delete(fn)
+	Create(fn, []byte("b"), 0600)
+
+	tmps, err := filepath.Glob(filepath.Join(DataDir(), fn+".tmp*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmps) != 0 {
+		t.Errorf("expected no temporary files, got %v", tmps)
+	}
+}
@@ -0,0 +1,29 @@
+// Package filestest has helpers for tests that use the files package.
+package filestest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/files"
+)
+
+// SetDataDir points the files package at a new temporary directory until
+// the test ends and returns the directory. Tests using it must not run in
+// parallel, the data directory is shared by the whole process.
+func SetDataDir(t testing.TB) string {
+	dir, err := ioutil.TempDir("", "nyzotest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prevDir := files.DataDir()
+	if err := files.SetDataDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		files.SetDataDir(prevDir)
+		os.RemoveAll(dir)
+	})
+	return dir
+}
@@ -0,0 +1,21 @@
+module github.com/qqvv/go-nyzo
+
+go 1.21
+
+require (
+	github.com/prometheus/client_golang v1.14.0
+	go.etcd.io/bbolt v1.3.10
+	golang.org/x/crypto v0.9.0
+)
+
+require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
+	github.com/matttproud/golang_protobuf_extensions v1.0.1 // indirect
+	github.com/prometheus/client_model v0.3.0 // indirect
+	github.com/prometheus/common v0.37.0 // indirect
+	github.com/prometheus/procfs v0.8.0 // indirect
+	golang.org/x/sys v0.8.0 // indirect
+	google.golang.org/protobuf v1.28.1 // indirect
+)
@@ -0,0 +1,363 @@
+package keystore
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files"
+)
+
+// DefaultFile is the keystore file in the data directory
+const DefaultFile = "keystore.json"
+
+const version = 1
+
+// scrypt parameters for new keystores, existing keystores keep theirs
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// limits for the scrypt parameters of opened keystores, scrypt uses
+// 128*N*r bytes of memory and p times the work
+const (
+	maxScryptN   = 1 << 20
+	maxScryptMem = 1 << 30
+	maxScryptRP  = 16
+)
+
+var (
+	ErrLocked        = errors.New("keystore is locked")
+	ErrWrongPassword = errors.New("wrong password")
+	ErrNotFound      = errors.New("key not found")
+	ErrExists        = errors.New("key already exists")
+)
+
+// the check value is encrypted with the derived key so the password can be
+// verified even when the keystore is empty
+var checkAD = []byte("nyzo keystore")
+
+type kdfParams struct {
+	Salt string `json:"salt"`
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+}
+
+// check rejects parameters that would make scrypt fail or take more memory
+// and time than any keystore we create
+func (params kdfParams) check() error {
+	if params.N < 2 || params.N > maxScryptN || params.N&(params.N-1) != 0 {
+		return fmt.Errorf("scrypt n %v is not a power of two up to %v", params.N, maxScryptN)
+	}
+	if params.R < 1 || params.P < 1 || params.R > maxScryptRP || params.P > maxScryptRP ||
+		params.R*params.P > maxScryptRP {
+		return fmt.Errorf("scrypt r %v and p %v out of range", params.R, params.P)
+	}
+	if 128*params.N*params.R > maxScryptMem {
+		return fmt.Errorf("scrypt n %v and r %v need too much memory", params.N, params.R)
+	}
+	return nil
+}
+
+type entry struct {
+	Name  string           `json:"name"`
+	ID    crypto.PublicKey `json:"id"`
+	Nonce string           `json:"nonce"`
+	Seed  string           `json:"seed"` // encrypted
+}
+
+type file struct {
+	Version    int       `json:"version"`
+	KDF        kdfParams `json:"kdf"`
+	CheckNonce string    `json:"checkNonce"`
+	Check      string    `json:"check"`
+	Keys       []*entry  `json:"keys"`
+}
+
+// Entry is the public part of a stored key
+type Entry struct {
+	Name string           `json:"name"`
+	ID   crypto.PublicKey `json:"id"`
+}
+
+// Keystore holds named private keys encrypted with a password. The names
+// and ids are stored in plain text, the seeds are encrypted with
+// XChaCha20-Poly1305 using a key derived from the password with scrypt.
+type Keystore struct {
+	filename string
+	f        *file
+
+	mu   sync.Mutex
+	aead cipher.AEAD // nil while locked
+}
+
+// Create creates a new keystore file in the data directory, it is unlocked
+// with password afterwards
+func Create(filename, password string) (*Keystore, error) {
+	f := &file{
+		Version: version,
+		KDF: kdfParams{
+			Salt: hex.EncodeToString(crypto.RandBytes(32)),
+			N:    scryptN,
+			R:    scryptR,
+			P:    scryptP,
+		},
+		Keys: []*entry{},
+	}
+	aead, err := deriveAEAD(f.KDF, password)
+	if err != nil {
+		return nil, err
+	}
+	nonce := crypto.RandBytes(aead.NonceSize())
+	f.CheckNonce = hex.EncodeToString(nonce)
+	f.Check = hex.EncodeToString(aead.Seal(nil, nonce, nil, checkAD))
+
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := files.Create(filename, b, 0600); err != nil {
+		return nil, err
+	}
+
+	return &Keystore{filename: filename, f: f, aead: aead}, nil
+}
+
+// Open reads a keystore file from the data directory, it is locked
+func Open(filename string) (*Keystore, error) {
+	b, err := files.ReadBytes(filename)
+	if err != nil {
+		return nil, err
+	}
+	f := &file{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	if f.Version != version {
+		return nil, fmt.Errorf("unsupported keystore version %v", f.Version)
+	}
+	if err := f.KDF.check(); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %v", err)
+	}
+	return &Keystore{filename: filename, f: f}, nil
+}
+
+func (ks *Keystore) Unlock(password string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	aead, err := deriveAEAD(ks.f.KDF, password)
+	if err != nil {
+		return err
+	}
+	nonce, err := hex.DecodeString(ks.f.CheckNonce)
+	if err != nil {
+		return err
+	}
+	check, err := hex.DecodeString(ks.f.Check)
+	if err != nil {
+		return err
+	}
+	if _, err := aead.Open(nil, nonce, check, checkAD); err != nil {
+		return ErrWrongPassword
+	}
+
+	ks.aead = aead
+	return nil
+}
+
+func (ks *Keystore) Lock() {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.aead = nil
+}
+
+func (ks *Keystore) Locked() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	return ks.aead == nil
+}
+
+// List returns the stored keys sorted by name, it works while locked
+func (ks *Keystore) List() []Entry {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	entries := make([]Entry, 0, len(ks.f.Keys))
+	for _, e := range ks.f.Keys {
+		entries = append(entries, Entry{Name: e.Name, ID: e.ID})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+	return entries
+}
+
+// Add generates a new key and stores it under name
+func (ks *Keystore) Add(name string) (crypto.PublicKey, error) {
+	privKey := crypto.GenPrivKey()
+	if err := ks.add(name, privKey); err != nil {
+		return crypto.PublicKey{}, err
+	}
+	return privKey.PubKey(), nil
+}
+
+// Import stores an existing key given as a seed in hex (with or without
+// dashes) or as a key_ nyzo string
+func (ks *Keystore) Import(name, seed string) (crypto.PublicKey, error) {
+	var privKey crypto.PrivateKey
+	var err error
+	seed = strings.TrimSpace(seed)
+	if strings.HasPrefix(seed, string(crypto.PrefixPrivateSeed)) {
+		privKey, err = crypto.ParseNyzoPrivateKey(seed)
+	} else {
+		privKey, err = crypto.ParsePrivateKey(seed)
+	}
+	if err != nil {
+		return crypto.PublicKey{}, fmt.Errorf("invalid seed: %v", err)
+	}
+
+	if err := ks.add(name, privKey); err != nil {
+		return crypto.PublicKey{}, err
+	}
+	return privKey.PubKey(), nil
+}
+
+// Export decrypts the key stored under name, the keystore must be unlocked
+func (ks *Keystore) Export(name string) (crypto.PrivateKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.aead == nil {
+		return crypto.PrivateKey{}, ErrLocked
+	}
+	e := ks.find(name)
+	if e == nil {
+		return crypto.PrivateKey{}, ErrNotFound
+	}
+
+	nonce, err := hex.DecodeString(e.Nonce)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	ciphertext, err := hex.DecodeString(e.Seed)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	seed, err := ks.aead.Open(nil, nonce, ciphertext, e.ID[:])
+	if err != nil || len(seed) != 32 {
+		return crypto.PrivateKey{}, fmt.Errorf("cannot decrypt key %v", name)
+	}
+
+	privKey := crypto.PrivateKey{}
+	copy(privKey[:], seed)
+	privKey.SetPubKey()
+	if privKey.PubKey() != e.ID {
+		return crypto.PrivateKey{}, fmt.Errorf("key %v does not match its id", name)
+	}
+	return privKey, nil
+}
+
+// Sign signs msg with the key stored under name
+func (ks *Keystore) Sign(name string, msg []byte) (crypto.Signature, error) {
+	privKey, err := ks.Export(name)
+	if err != nil {
+		return crypto.Signature{}, err
+	}
+	return privKey.Sign(msg), nil
+}
+
+func (ks *Keystore) Remove(name string) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.aead == nil {
+		return ErrLocked
+	}
+	for i, e := range ks.f.Keys {
+		if e.Name == name {
+			keys := make([]*entry, 0, len(ks.f.Keys)-1)
+			keys = append(keys, ks.f.Keys[:i]...)
+			return ks.save(append(keys, ks.f.Keys[i+1:]...))
+		}
+	}
+	return ErrNotFound
+}
+
+func (ks *Keystore) add(name string, privKey crypto.PrivateKey) error {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if ks.aead == nil {
+		return ErrLocked
+	}
+	if name == "" {
+		return fmt.Errorf("key name must not be empty")
+	}
+	if ks.find(name) != nil {
+		return ErrExists
+	}
+
+	id := privKey.PubKey()
+	nonce := crypto.RandBytes(ks.aead.NonceSize())
+	keys := make([]*entry, len(ks.f.Keys), len(ks.f.Keys)+1)
+	copy(keys, ks.f.Keys)
+	return ks.save(append(keys, &entry{
+		Name:  name,
+		ID:    id,
+		Nonce: hex.EncodeToString(nonce),
+		// the id is authenticated so entries can't be mixed up
+		Seed: hex.EncodeToString(ks.aead.Seal(nil, nonce, privKey[:32], id[:])),
+	}))
+}
+
+func (ks *Keystore) find(name string) *entry {
+	for _, e := range ks.f.Keys {
+		if e.Name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// save writes the file with keys and only keeps them if that worked
+func (ks *Keystore) save(keys []*entry) error {
+	f := *ks.f
+	f.Keys = keys
+	b, err := json.MarshalIndent(&f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := files.WriteMode(ks.filename, b, 0600); err != nil {
+		return err
+	}
+	ks.f.Keys = keys
+	return nil
+}
+
+func deriveAEAD(params kdfParams, password string) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P,
+		chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
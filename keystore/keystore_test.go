@@ -0,0 +1,195 @@
+package keystore
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files"
+	"github.com/qqvv/go-nyzo/files/filestest"
+)
+
+func init() {
+	// keep the tests fast
+	scryptN = 1 << 10
+}
+
+func TestKeystore(t *testing.T) {
+	filestest.SetDataDir(t)
+
+	ks, err := Create(DefaultFile, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Create(DefaultFile, "password"); err == nil {
+		t.Error("expected creating over an existing keystore to fail")
+	}
+
+	imported := crypto.GenPrivKey()
+	generatedID, err := ks.Add("generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Import("hex", imported.StringWithDashes()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Import("nyzostring", crypto.GenPrivKey().NyzoString()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Import("invalid", "abcd"); err == nil {
+		t.Error("expected importing an invalid seed to fail")
+	}
+	if _, err := ks.Add("hex"); err != ErrExists {
+		t.Errorf("expected ErrExists, got %v", err)
+	}
+
+	// reopen from disk
+	ks, err = Open(DefaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := ks.List()
+	if len(entries) != 3 || entries[0].Name != "generated" || entries[0].ID != generatedID {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	if _, err := ks.Export("hex"); err != ErrLocked {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+	if err := ks.Unlock("wrong"); err != ErrWrongPassword {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+	if err := ks.Unlock("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := ks.Export("hex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if privKey != imported {
+		t.Error("exported key does not match imported key")
+	}
+
+	msg := []byte("test")
+	sig, err := ks.Sign("generated", msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !generatedID.Verify(msg, sig) {
+		t.Error("invalid signature from keystore")
+	}
+
+	if err := ks.Remove("hex"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Export("hex"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	ks.Lock()
+	if _, err := ks.Sign("generated", msg); err != ErrLocked {
+		t.Errorf("expected ErrLocked, got %v", err)
+	}
+}
+
+func TestKeystoreNoPlainSeed(t *testing.T) {
+	filestest.SetDataDir(t)
+
+	ks, err := Create(DefaultFile, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey := crypto.GenPrivKey()
+	if _, err := ks.Import("key", privKey.String()); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := files.ReadString(DefaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, seed := range []string{privKey.String(), privKey.StringWithDashes(), privKey.NyzoString()} {
+		if strings.Contains(s, seed) {
+			t.Error("keystore file contains the plain seed")
+		}
+	}
+}
+
+func TestKeystoreFailedSave(t *testing.T) {
+	filestest.SetDataDir(t)
+
+	ks, err := Create(DefaultFile, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.Add("kept"); err != nil {
+		t.Fatal(err)
+	}
+	// writes fail without the data directory
+	if err := os.RemoveAll(files.DataDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ks.Add("added"); err == nil {
+		t.Error("expected adding to fail")
+	}
+	if err := ks.Remove("kept"); err == nil {
+		t.Error("expected removing to fail")
+	}
+	if entries := ks.List(); len(entries) != 1 || entries[0].Name != "kept" {
+		t.Errorf("expected the keys to stay as they were, got %+v", entries)
+	}
+}
+
+func TestKeystoreKDFParams(t *testing.T) {
+	filestest.SetDataDir(t)
+
+	if _, err := Create(DefaultFile, "password"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := files.ReadBytes(DefaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := &file{}
+	if err := json.Unmarshal(b, valid); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		n, r, p int
+		valid   bool
+	}{
+		{1 << 10, 8, 1, true},
+		{1 << 20, 8, 1, true},
+		{1 << 21, 8, 1, false},
+		{1<<10 + 1, 8, 1, false},
+		{0, 8, 1, false},
+		{1 << 20, 16, 1, false},
+		{1 << 10, 8, 4, false},
+		{1 << 10, 0, 1, false},
+		{1 << 10, 1 << 32, 1 << 32, false},
+	}
+
+	for i, test := range tests {
+		f := *valid
+		f.KDF.N, f.KDF.R, f.KDF.P = test.n, test.r, test.p
+		b, err := json.Marshal(&f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := files.Write(DefaultFile, b); err != nil {
+			t.Fatal(err)
+		}
+		_, err = Open(DefaultFile)
+		if test.valid && err != nil {
+			t.Errorf("opening failed (%v): %v", i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected opening to fail (%v)", i)
+		}
+	}
+}
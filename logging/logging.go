@@ -0,0 +1,196 @@
+// Package logging gives every subsystem its own structured logger with a
+// level that can be changed at runtime. All loggers share one output,
+// text or json, set with SetOutput.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	mu           sync.Mutex
+	levels       = map[string]*slog.LevelVar{}
+	defaultLevel = slog.LevelInfo
+
+	// output is the shared handler all subsystem loggers write to
+	output atomic.Value
+)
+
+func init() {
+	output.Store(handlerBox{slog.NewTextHandler(os.Stderr, allLevels)})
+}
+
+// handlerBox keeps atomic.Value happy with different handler types
+type handlerBox struct {
+	slog.Handler
+}
+
+// the shared handler lets everything through, the subsystems filter
+var allLevels = &slog.HandlerOptions{Level: slog.Level(-1 << 10)}
+
+// SetOutput sets where and in which format all loggers write
+func SetOutput(w io.Writer, format string) error {
+	var h slog.Handler
+	switch format {
+	case FormatText, "":
+		h = slog.NewTextHandler(w, allLevels)
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, allLevels)
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	output.Store(handlerBox{h})
+	return nil
+}
+
+// Logger returns the logger of subsystem, e.g. "block". Loggers are cheap
+// and usually kept in a package variable.
+func Logger(subsystem string) *slog.Logger {
+	h := &handler{level: levelVar(subsystem)}
+	return slog.New(h).With("subsystem", subsystem)
+}
+
+func levelVar(subsystem string) *slog.LevelVar {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lv, ok := levels[subsystem]
+	if !ok {
+		lv = &slog.LevelVar{}
+		lv.Set(defaultLevel)
+		levels[subsystem] = lv
+	}
+	return lv
+}
+
+// SetLevel sets the level of subsystem, or of all subsystems including
+// the ones created later if subsystem is empty
+func SetLevel(subsystem string, level slog.Level) {
+	if subsystem != "" {
+		levelVar(subsystem).Set(level)
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	defaultLevel = level
+	for _, lv := range levels {
+		lv.Set(level)
+	}
+}
+
+// SetLevels applies a spec like "info,network=debug,files=warn", a level
+// without a subsystem applies to all others. It replaces the levels set
+// before, subsystems that are not in spec get the level for all of them,
+// info if spec has none.
+func SetLevels(spec string) error {
+	levels, err := ParseLevels(spec)
+	if err != nil {
+		return err
+	}
+
+	// the default first so it doesn't override the subsystems
+	level, ok := levels[""]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	SetLevel("", level)
+	for subsystem, level := range levels {
+		if subsystem != "" {
+			SetLevel(subsystem, level)
+		}
+	}
+	return nil
+}
+
+// ParseLevels parses a spec for SetLevels, the level for all subsystems is
+// under ""
+func ParseLevels(spec string) (map[string]slog.Level, error) {
+	levels := map[string]slog.Level{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		subsystem, name := "", part
+		if i := strings.Index(part, "="); i >= 0 {
+			subsystem, name = strings.TrimSpace(part[:i]), strings.TrimSpace(part[i+1:])
+			if subsystem == "" {
+				return nil, fmt.Errorf("missing subsystem in %q", part)
+			}
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		levels[subsystem] = level
+	}
+	return levels, nil
+}
+
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// Levels returns the current level of every subsystem
+func Levels() map[string]slog.Level {
+	mu.Lock()
+	defer mu.Unlock()
+
+	m := make(map[string]slog.Level, len(levels))
+	for subsystem, lv := range levels {
+		m[subsystem] = lv.Level()
+	}
+	return m
+}
+
+// handler filters by the level of its subsystem and writes to the current
+// output. Attributes and groups are replayed on the output as it may have
+// changed since they were added.
+type handler struct {
+	level *slog.LevelVar
+	ops   []func(slog.Handler) slog.Handler
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	out := output.Load().(handlerBox).Handler
+	for _, op := range h.ops {
+		out = op(out)
+	}
+	return out.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithAttrs(attrs) })
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return h.with(func(out slog.Handler) slog.Handler { return out.WithGroup(name) })
+}
+
+func (h *handler) with(op func(slog.Handler) slog.Handler) *handler {
+	ops := make([]func(slog.Handler) slog.Handler, len(h.ops), len(h.ops)+1)
+	copy(ops, h.ops)
+	return &handler{level: h.level, ops: append(ops, op)}
+}
@@ -0,0 +1,107 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := SetOutput(buf, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	defer SetOutput(os.Stderr, FormatText)
+	defer SetLevel("", slog.LevelInfo)
+
+	a, b := Logger("testa"), Logger("testb").With("k", "v")
+	if err := SetLevels("warn, testb=debug"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		log      *slog.Logger
+		level    slog.Level
+		expected bool
+	}{
+		{a, slog.LevelInfo, false},
+		{a, slog.LevelWarn, true},
+		{b, slog.LevelDebug, true},
+		// created after SetLevels, gets the default
+		{Logger("testc"), slog.LevelInfo, false},
+		{Logger("testc"), slog.LevelError, true},
+	}
+
+	for i, test := range tests {
+		buf.Reset()
+		test.log.Log(context.Background(), test.level, "hello")
+		if logged := buf.Len() > 0; logged != test.expected {
+			t.Errorf("expected logged %v, got %v (%v)", test.expected, logged, i)
+		}
+	}
+
+	// attributes survive a change of the output
+	out := new(bytes.Buffer)
+	if err := SetOutput(out, FormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	b.Debug("hello", "n", 1)
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(out.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["subsystem"] != "testb" || m["k"] != "v" || m["n"] != 1.0 || m["msg"] != "hello" {
+		t.Errorf("unexpected json log %v", m)
+	}
+
+	if Levels()["testb"] != slog.LevelDebug {
+		t.Errorf("unexpected levels %v", Levels())
+	}
+
+	// overrides left out of a new spec are reset
+	if err := SetLevels("error"); err != nil {
+		t.Fatal(err)
+	}
+	if Levels()["testb"] != slog.LevelError {
+		t.Errorf("expected testb to be reset to error, got %v", Levels()["testb"])
+	}
+	if err := SetLevels("testa=debug"); err != nil {
+		t.Fatal(err)
+	}
+	if Levels()["testa"] != slog.LevelDebug || Levels()["testb"] != slog.LevelInfo {
+		t.Errorf("unexpected levels %v", Levels())
+	}
+}
+
+func TestParseLevels(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"", true},
+		{"debug", true},
+		{"INFO,block=warn,files=error", true},
+		{"info,block=debug-4", true},
+		{"loud", false},
+		{"info,=debug", false},
+		{"block=", false},
+	}
+
+	for i, test := range tests {
+		_, err := ParseLevels(test.spec)
+		if test.valid && err != nil {
+			t.Errorf("parsing %q failed (%v): %v", test.spec, i, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("expected parsing %q to fail (%v)", test.spec, i)
+		}
+	}
+
+	if err := SetOutput(os.Stderr, "xml"); err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Errorf("expected an unknown format error, got %v", err)
+	}
+}
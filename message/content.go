@@ -0,0 +1,205 @@
+package message
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+type TransactionResponseContent struct {
+	Accepted bool
+	Message  string
+}
+
+func (c *TransactionResponseContent) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	if c.Accepted {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	writeString(buf, c.Message)
+	return buf.Bytes()
+}
+
+func (c *TransactionResponseContent) Deserialize(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	accepted, err := buf.ReadByte()
+	if err != nil {
+		return fmt.Errorf("cannot deserialize TransactionResponse: %v", err)
+	}
+	c.Accepted = accepted == 1
+	c.Message, err = readString(buf)
+	return err
+}
+
+// strings are prefixed with their length as int16
+func writeString(buf *bytes.Buffer, s string) {
+	binary.Write(buf, binary.BigEndian, int16(len(s)))
+	buf.WriteString(s)
+}
+
+func readString(buf *bytes.Buffer) (string, error) {
+	var n int16
+	if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+		return "", fmt.Errorf("cannot read string length: %v", err)
+	}
+	if n < 0 || int(n) > buf.Len() {
+		return "", fmt.Errorf("invalid string length %v", n)
+	}
+	return string(buf.Next(int(n))), nil
+}
+
+type PrevHashResponseContent struct {
+	Height int64
+	Hash   crypto.Hash
+}
+
+func (c *PrevHashResponseContent) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, c.Height)
+	binary.Write(buf, binary.BigEndian, c.Hash)
+	return buf.Bytes()
+}
+
+func (c *PrevHashResponseContent) Deserialize(b []byte) error {
+	if len(b) < 40 {
+		return fmt.Errorf("cannot deserialize PrevHashResponse: too short")
+	}
+	buf := bytes.NewBuffer(b)
+	binary.Read(buf, binary.BigEndian, &c.Height)
+	binary.Read(buf, binary.BigEndian, &c.Hash)
+	return nil
+}
+
+// TimestampResponseContent is the time of the responding node in nano,
+// it is milli on the wire
+type TimestampResponseContent struct {
+	Timestamp int64
+}
+
+func (c *TimestampResponseContent) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, c.Timestamp/1000/1000)
+	return buf.Bytes()
+}
+
+func (c *TimestampResponseContent) Deserialize(b []byte) error {
+	if len(b) < 8 {
+		return fmt.Errorf("cannot deserialize TimestampResponse: too short")
+	}
+	c.Timestamp = int64(binary.BigEndian.Uint64(b)) * 1000 * 1000
+	return nil
+}
+
+// BlacklistStatusResponseContent starts with the message as a string,
+// which is all that nyzoVerifier sends, whether the node is banned and
+// until when follow
+type BlacklistStatusResponseContent struct {
+	Message string
+	Banned  bool
+	// BannedUntil is in nano, milli on the wire
+	BannedUntil int64
+}
+
+func (c *BlacklistStatusResponseContent) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	writeString(buf, c.Message)
+	if c.Banned {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+	binary.Write(buf, binary.BigEndian, c.BannedUntil/1000/1000)
+	return buf.Bytes()
+}
+
+func (c *BlacklistStatusResponseContent) Deserialize(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	var err error
+	if c.Message, err = readString(buf); err != nil {
+		return fmt.Errorf("cannot deserialize BlacklistStatusResponse: %v", err)
+	}
+	c.Banned, c.BannedUntil = false, 0
+	if buf.Len() < 9 {
+		return nil
+	}
+	banned, _ := buf.ReadByte()
+	c.Banned = banned == 1
+	var until int64
+	binary.Read(buf, binary.BigEndian, &until)
+	c.BannedUntil = until * 1000 * 1000
+	return nil
+}
+
+// MultilineTextResponseContent is the response of the admin requests, the
+// lines are for people to read
+type MultilineTextResponseContent struct {
+	Lines []string
+}
+
+func (c *MultilineTextResponseContent) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, int16(len(c.Lines)))
+	for _, line := range c.Lines {
+		writeString(buf, line)
+	}
+	return buf.Bytes()
+}
+
+func (c *MultilineTextResponseContent) Deserialize(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	var n int16
+	if err := binary.Read(buf, binary.BigEndian, &n); err != nil {
+		return fmt.Errorf("cannot deserialize MultilineTextResponse: %v", err)
+	}
+	if n < 0 {
+		return fmt.Errorf("cannot deserialize MultilineTextResponse: invalid line count %v", n)
+	}
+	c.Lines = make([]string, 0, n)
+	for i := 0; i < int(n); i++ {
+		line, err := readString(buf)
+		if err != nil {
+			return fmt.Errorf("cannot deserialize MultilineTextResponse: %v", err)
+		}
+		c.Lines = append(c.Lines, line)
+	}
+	return nil
+}
+
+// PingResponseContent starts with the version as a string, which is all
+// that nyzoVerifier sends, the frozen edge and uptime follow
+type PingResponseContent struct {
+	Version string
+	// FrozenEdge is -1 if the node has no blocks or didn't send it
+	FrozenEdge int64
+	// Uptime is in nano, milli on the wire
+	Uptime int64
+}
+
+func (c *PingResponseContent) Serialize() []byte {
+	buf := new(bytes.Buffer)
+	writeString(buf, c.Version)
+	binary.Write(buf, binary.BigEndian, c.FrozenEdge)
+	binary.Write(buf, binary.BigEndian, c.Uptime/1000/1000)
+	return buf.Bytes()
+}
+
+func (c *PingResponseContent) Deserialize(b []byte) error {
+	buf := bytes.NewBuffer(b)
+	var err error
+	if c.Version, err = readString(buf); err != nil {
+		return fmt.Errorf("cannot deserialize PingResponse: %v", err)
+	}
+	c.FrozenEdge, c.Uptime = -1, 0
+	if buf.Len() < 16 {
+		return nil
+	}
+	var uptime int64
+	binary.Read(buf, binary.BigEndian, &c.FrozenEdge)
+	binary.Read(buf, binary.BigEndian, &uptime)
+	c.Uptime = uptime * 1000 * 1000
+	return nil
+}
@@ -4,11 +4,18 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"time"
+	"math"
 
+	"github.com/qqvv/go-nyzo/clock"
 	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/logging"
 )
 
+// maxTimestamp is the largest timestamp in milli that fits in nano
+const maxTimestamp = math.MaxInt64 / 1000 / 1000
+
+var log = logging.Logger("message")
+
 type Msg struct {
 	Timestamp int64
 	Type      MsgType
@@ -54,9 +61,20 @@ func (msg *Msg) Deserialize(i interface{}) error {
 		return fmt.Errorf("cannot deserialize Msg from %#v", i)
 	}
 
+	// timestamp (8) + type (2) + id (32) + sig (64)
+	if buf.Len() < 106 {
+		return fmt.Errorf("message too short")
+	}
 	binary.Read(buf, binary.BigEndian, &msg.Timestamp)
+	if msg.Timestamp > maxTimestamp || msg.Timestamp < -maxTimestamp {
+		return fmt.Errorf("message timestamp out of range: %v", msg.Timestamp)
+	}
 	msg.Timestamp *= 1000 * 1000 // to nano
 	binary.Read(buf, binary.BigEndian, &msg.Type)
+
+	// the content is everything up to the id and signature
+	contentLen := buf.Len() - 96
+	msg.Content = make([]byte, contentLen)
 	binary.Read(buf, binary.BigEndian, &msg.Content)
 	binary.Read(buf, binary.BigEndian, &msg.ID)
 	binary.Read(buf, binary.BigEndian, &msg.Sig)
@@ -74,12 +92,22 @@ func (msg *Msg) SerializedLen() int {
 }
 
 func (msg *Msg) VerifySig() bool {
-	return msg.ID.Verify(msg.ForSigning(), msg.Sig)
+	if !msg.ID.Verify(msg.ForSigning(), msg.Sig) {
+		log.Debug("invalid message signature", "type", msg.Type, "id", msg.ID.StringCompact())
+		return false
+	}
+	return true
 }
 
+// New is NewWithClock with the system clock
 func New(msgType int) *Msg {
+	return NewWithClock(clock.System{}, msgType)
+}
+
+// NewWithClock timestamps the message with the time of c
+func NewWithClock(c clock.Clock, msgType int) *Msg {
 	msg := &Msg{
-		Timestamp: time.Now().UnixNano(),
+		Timestamp: c.Now().UnixNano(),
 		Type:      MsgType(msgType),
 	}
 	return msg
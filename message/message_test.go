@@ -0,0 +1,195 @@
+package message
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+func FuzzMsgDeserialize(f *testing.F) {
+	privKey := crypto.GenPrivKey()
+	empty := New(int(PrevHashRequest))
+	empty.Sign(privKey)
+	withContent := New(int(TransactionResponse))
+	withContent.Content = (&TransactionResponseContent{Accepted: true, Message: "ok"}).Serialize()
+	withContent.Sign(privKey)
+
+	for _, msg := range []*Msg{empty, withContent} {
+		b := msg.Serialize()[4:] // without length
+		f.Add(b)
+		f.Add(b[:len(b)-1])
+	}
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg := &Msg{}
+		if err := msg.Deserialize(data); err != nil {
+			return
+		}
+		if b := msg.Serialize()[4:]; !bytes.Equal(b, data) {
+			t.Fatalf("re-encoded message differs\nexpected %x\ngot      %x", data, b)
+		}
+	})
+}
+
+func FuzzTransactionResponseDeserialize(f *testing.F) {
+	f.Add((&TransactionResponseContent{Accepted: true, Message: "accepted"}).Serialize())
+	f.Add((&TransactionResponseContent{Message: "not accepted"}).Serialize())
+	f.Add([]byte{1, 0xff, 0xff})
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := &TransactionResponseContent{}
+		if err := c.Deserialize(data); err != nil {
+			return
+		}
+		b := c.Serialize()
+		if len(b) > len(data) {
+			t.Fatalf("re-encoded %v bytes from %v", len(b), len(data))
+		}
+		expected := append([]byte{}, data[:len(b)]...)
+		// anything but 1 is not accepted
+		if expected[0] != 1 {
+			expected[0] = 0
+		}
+		if !bytes.Equal(b, expected) {
+			t.Fatalf("re-encoded content differs\nexpected %x\ngot      %x", expected, b)
+		}
+	})
+}
+
+func FuzzPrevHashResponseDeserialize(f *testing.F) {
+	f.Add((&PrevHashResponseContent{Height: 10, Hash: crypto.DoubleSHA256([]byte("10"))}).Serialize())
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := &PrevHashResponseContent{}
+		if err := c.Deserialize(data); err != nil {
+			return
+		}
+		if b := c.Serialize(); !bytes.Equal(b, data[:40]) {
+			t.Fatalf("re-encoded content differs\nexpected %x\ngot      %x", data[:40], b)
+		}
+	})
+}
+
+func TestMsgTypeString(t *testing.T) {
+	tests := []struct {
+		msgType MsgType
+		name    string
+	}{
+		{Transaction, "Transaction"},
+		{PrevHashResponse, "PrevHashResponse"},
+		{Ping, "Ping"},
+		{BlacklistStatusResponse, "BlacklistStatusResponse"},
+		{Unknown, "Unknown"},
+		{2, "MsgType(2)"},
+	}
+
+	for i, test := range tests {
+		if name := test.msgType.String(); name != test.name {
+			t.Errorf("expected %v, got %v (%v)", test.name, name, i)
+		}
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 123456789, time.UTC)
+	if msg := NewWithClock(clock.NewManual(now), int(Ping)); msg.Timestamp != now.UnixNano() {
+		t.Errorf("unexpected message timestamp %v", msg.Timestamp)
+	}
+
+	// milli on the wire
+	content := &TimestampResponseContent{Timestamp: now.UnixNano()}
+	decoded := &TimestampResponseContent{}
+	if err := decoded.Deserialize(content.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Timestamp != now.Truncate(time.Millisecond).UnixNano() {
+		t.Errorf("unexpected timestamp %v", decoded.Timestamp)
+	}
+	if err := decoded.Deserialize([]byte{1, 2, 3}); err == nil {
+		t.Error("expected a short timestamp to fail")
+	}
+}
+
+func TestMultilineText(t *testing.T) {
+	tests := [][]string{
+		{},
+		{""},
+		{"paused: true", "mesh: 3 verifiers"},
+	}
+
+	for i, lines := range tests {
+		content := &MultilineTextResponseContent{Lines: lines}
+		decoded := &MultilineTextResponseContent{}
+		if err := decoded.Deserialize(content.Serialize()); err != nil {
+			t.Fatalf("deserializing failed (%v): %v", i, err)
+		}
+		if !reflect.DeepEqual(decoded.Lines, lines) {
+			t.Errorf("expected %q, got %q (%v)", lines, decoded.Lines, i)
+		}
+	}
+
+	for i, b := range [][]byte{{}, {0}, {0xff, 0xff}, {0, 1, 0, 5, 'a'}} {
+		if err := (&MultilineTextResponseContent{}).Deserialize(b); err == nil {
+			t.Errorf("expected deserializing to fail (%v)", i)
+		}
+	}
+}
+
+func TestBlacklistStatusResponse(t *testing.T) {
+	content := &BlacklistStatusResponseContent{Message: "banned", Banned: true, BannedUntil: int64(time.Hour + 1)}
+	decoded := &BlacklistStatusResponseContent{}
+	if err := decoded.Deserialize(content.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	expected := BlacklistStatusResponseContent{Message: "banned", Banned: true, BannedUntil: int64(time.Hour)}
+	if *decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+
+	// nyzoVerifier only sends a string
+	buf := new(bytes.Buffer)
+	writeString(buf, "not banned")
+	if err := decoded.Deserialize(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	expected = BlacklistStatusResponseContent{Message: "not banned"}
+	if *decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+	if err := decoded.Deserialize([]byte{0}); err == nil {
+		t.Error("expected a short response to fail")
+	}
+}
+
+func TestPingResponse(t *testing.T) {
+	content := &PingResponseContent{Version: "v0.1.0", FrozenEdge: 42, Uptime: int64(90*time.Minute + 1)}
+	decoded := &PingResponseContent{}
+	if err := decoded.Deserialize(content.Serialize()); err != nil {
+		t.Fatal(err)
+	}
+	expected := PingResponseContent{Version: "v0.1.0", FrozenEdge: 42, Uptime: int64(90 * time.Minute)}
+	if *decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+
+	// nyzoVerifier only sends a string
+	buf := new(bytes.Buffer)
+	writeString(buf, "hello, v620")
+	if err := decoded.Deserialize(buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	expected = PingResponseContent{Version: "hello, v620", FrozenEdge: -1}
+	if *decoded != expected {
+		t.Errorf("expected %+v, got %+v", expected, decoded)
+	}
+	if err := decoded.Deserialize([]byte{0}); err == nil {
+		t.Error("expected a short response to fail")
+	}
+}
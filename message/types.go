@@ -1,5 +1,7 @@
 package message
 
+import "fmt"
+
 type MsgType uint16
 
 const (
@@ -83,3 +85,75 @@ const (
 	Error
 	Unknown
 )
+
+var msgTypeNames = map[MsgType]string{
+	Invalid:                            "Invalid",
+	NodeJoin:                           "NodeJoin",
+	NodeJoinResponse:                   "NodeJoinResponse",
+	Transaction:                        "Transaction",
+	TransactionResponse:                "TransactionResponse",
+	PrevHashRequest:                    "PrevHashRequest",
+	PrevHashResponse:                   "PrevHashResponse",
+	NewBlock:                           "NewBlock",
+	NewBlockResponse:                   "NewBlockResponse",
+	BlockRequest:                       "BlockRequest",
+	BlockResponse:                      "BlockResponse",
+	TxPoolRequest:                      "TxPoolRequest",
+	TxPoolResponse:                     "TxPoolResponse",
+	MeshRequest:                        "MeshRequest",
+	MeshResponse:                       "MeshResponse",
+	BlockVote:                          "BlockVote",
+	BlockVoteResponse:                  "BlockVoteResponse",
+	NewVerivierVote:                    "NewVerivierVote",
+	NewVerivierVoteResponse:            "NewVerivierVoteResponse",
+	MissingBlockVoteRequest:            "MissingBlockVoteRequest",
+	MissingBlockVoteResponse:           "MissingBlockVoteResponse",
+	MissingBlockRequest:                "MissingBlockRequest",
+	MissingBlockResponse:               "MissingBlockResponse",
+	TimestampRequest:                   "TimestampRequest",
+	TimestampResponse:                  "TimestampResponse",
+	HashVoteOverrideRequest:            "HashVoteOverrideRequest",
+	HashVoteOverrideResponse:           "HashVoteOverrideResponse",
+	ConsensusThresholdOverrideRequest:  "ConsensusThresholdOverrideRequest",
+	ConsensusThresholdOverrideResponse: "ConsensusThresholdOverrideResponse",
+	NewVerivierVoteOverrideRequest:     "NewVerivierVoteOverrideRequest",
+	NewVerivierVoteOverrideResponse:    "NewVerivierVoteOverrideResponse",
+	BootstrapRequestV2:                 "BootstrapRequestV2",
+	BootstrapResponseV2:                "BootstrapResponseV2",
+	BlockWithVotesRequest:              "BlockWithVotesRequest",
+	BlockWithVotesResponse:             "BlockWithVotesResponse",
+	Ping:                               "Ping",
+	PingResponse:                       "PingResponse",
+	UpdateRequest:                      "UpdateRequest",
+	UpdateResponse:                     "UpdateResponse",
+	BlockRejectionRequest:              "BlockRejectionRequest",
+	BlockRejectionResponse:             "BlockRejectionResponse",
+	DetachmentRequest:                  "DetachmentRequest",
+	DetachmentResponse:                 "DetachmentResponse",
+	UnfrozenBlockPoolPurgeRequest:      "UnfrozenBlockPoolPurgeRequest",
+	UnfrozenBlockPoolPurgeResponse:     "UnfrozenBlockPoolPurgeResponse",
+	UnfrozenBlockPoolStatusRequest:     "UnfrozenBlockPoolStatusRequest",
+	UnfrozenBlockPoolStatusResponse:    "UnfrozenBlockPoolStatusResponse",
+	MeshStatusRequest:                  "MeshStatusRequest",
+	MeshStatusResponse:                 "MeshStatusResponse",
+	TogglePauseRequest:                 "TogglePauseRequest",
+	TogglePauseResponse:                "TogglePauseResponse",
+	ConsensusTallyStatusRequest:        "ConsensusTallyStatusRequest",
+	ConsensusTallyStatusResponse:       "ConsensusTallyStatusResponse",
+	NewVerifierTallyStatusRequest:      "NewVerifierTallyStatusRequest",
+	NewVerifierTallyStatusResponse:     "NewVerifierTallyStatusResponse",
+	BlacklistStatusRequest:             "BlacklistStatusRequest",
+	BlacklistStatusResponse:            "BlacklistStatusResponse",
+	ResetRequest:                       "ResetRequest",
+	ResetResponse:                      "ResetResponse",
+	IncomingRequest:                    "IncomingRequest",
+	Error:                              "Error",
+	Unknown:                            "Unknown",
+}
+
+func (t MsgType) String() string {
+	if name, ok := msgTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("MsgType(%d)", uint16(t))
+}
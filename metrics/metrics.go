@@ -0,0 +1,120 @@
+// Package metrics holds the prometheus metrics of a node, they are served
+// by Handler on a registry of their own.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+const namespace = "nyzo"
+
+var Registry = prometheus.NewRegistry()
+
+var (
+	FrozenEdgeHeight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "frozen_edge_height",
+		Help:      "Height of the frozen edge.",
+	})
+
+	// BlocksFrozen gives the blocks frozen per minute as
+	// rate(nyzo_blocks_frozen_total[5m]) * 60
+	BlocksFrozen = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blocks_frozen_total",
+		Help:      "Number of blocks frozen since the start.",
+	})
+
+	// VoteTally is set by block voting for the height above the frozen edge
+	VoteTally = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "vote_tally",
+		Help:      "Votes for the leading and all other hashes at the height above the frozen edge.",
+	}, []string{"hash"})
+
+	PoolSize = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "pool_txs",
+		Help:      "Number of txes in the tx pool.",
+	})
+
+	MeshSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "mesh_nodes",
+		Help:      "Number of nodes in the mesh, in and out of the cycle.",
+	}, []string{"cycle"})
+
+	MessagesReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_received_total",
+		Help:      "Number of messages received by type.",
+	}, []string{"type"})
+
+	MessagesSent = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "messages_sent_total",
+		Help:      "Number of messages sent by type.",
+	}, []string{"type"})
+
+	FileWriteSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "file_write_seconds",
+		Help:      "Latency of writes to the file store.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8), // 0.1ms to 1.6s
+	})
+
+	ClockOffset = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "clock_offset_seconds",
+		Help:      "How far the local clock is behind the median of the peers.",
+	})
+
+	sigFailures = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signature_failures_total",
+		Help:      "Number of signatures that failed verification.",
+	}, func() float64 { return float64(crypto.VerifyFailures()) })
+
+	sigCacheHits = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sig_cache_hits_total",
+		Help:      "Number of tx signature verifications served from the cache.",
+	}, func() float64 { return float64(transaction.SigCache.Stats().Hits) })
+
+	sigCacheMisses = prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sig_cache_misses_total",
+		Help:      "Number of tx signature verifications not in the cache.",
+	}, func() float64 { return float64(transaction.SigCache.Stats().Misses) })
+)
+
+func init() {
+	Registry.MustRegister(
+		FrozenEdgeHeight,
+		BlocksFrozen,
+		VoteTally,
+		PoolSize,
+		MeshSize,
+		MessagesReceived,
+		MessagesSent,
+		FileWriteSeconds,
+		ClockOffset,
+		sigFailures,
+		sigCacheHits,
+		sigCacheMisses,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the metrics in the prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+func TestHandler(t *testing.T) {
+	FrozenEdgeHeight.Set(12)
+	MessagesReceived.WithLabelValues("Ping").Inc()
+	PoolSize.Set(3)
+	MeshSize.WithLabelValues("true").Set(5)
+	VoteTally.WithLabelValues("leading").Set(4)
+	privKey := crypto.GenPrivKey()
+	privKey.PubKey().Verify([]byte("msg"), crypto.Signature{})
+
+	srv := httptest.NewServer(Handler())
+	defer srv.Close()
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := string(b)
+
+	for _, line := range []string{
+		"nyzo_frozen_edge_height 12",
+		`nyzo_messages_received_total{type="Ping"} 1`,
+		"nyzo_pool_txs 3",
+		`nyzo_mesh_nodes{cycle="true"} 5`,
+		`nyzo_vote_tally{hash="leading"} 4`,
+		"nyzo_signature_failures_total 1",
+		"nyzo_sig_cache_hits_total 0",
+		"nyzo_file_write_seconds_count 0",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in metrics", line)
+		}
+	}
+}
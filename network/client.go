@@ -0,0 +1,148 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/metrics"
+)
+
+const (
+	DefaultPort = 9444
+
+	// MaxMsgLen limits the size of messages read from the network
+	MaxMsgLen = 10 * 1024 * 1024
+)
+
+var DefaultTimeout = 10 * time.Second
+
+// WithDefaultPort adds the DefaultPort to addr if it has no port
+func WithDefaultPort(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return net.JoinHostPort(addr, strconv.Itoa(DefaultPort))
+	}
+	return addr
+}
+
+// Fetch sends msg to the node at addr (host:port) over TCP and returns the
+// node's response
+func Fetch(addr string, msg *message.Msg) (*message.Msg, error) {
+	conn, err := net.DialTimeout("tcp", addr, DefaultTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(DefaultTimeout))
+
+	if _, err := conn.Write(msg.Serialize()); err != nil {
+		return nil, err
+	}
+	metrics.MessagesSent.WithLabelValues(msg.Type.String()).Inc()
+
+	resp, err := ReadMsg(conn)
+	if err != nil {
+		return nil, err
+	}
+	metrics.MessagesReceived.WithLabelValues(resp.Type.String()).Inc()
+	return resp, nil
+}
+
+// ErrMalformed is returned by ReadMsg for messages that were read but are
+// not valid, as opposed to errors of the connection
+var ErrMalformed = errors.New("network: malformed message")
+
+// ReadMsg reads a single length prefixed message
+func ReadMsg(r io.Reader) (*message.Msg, error) {
+	var msgLen int32
+	if err := binary.Read(r, binary.BigEndian, &msgLen); err != nil {
+		return nil, err
+	}
+	// the length includes its own 4 bytes
+	if msgLen < 4 || msgLen > MaxMsgLen {
+		return nil, fmt.Errorf("%w: invalid length %v", ErrMalformed, msgLen)
+	}
+
+	b := make([]byte, msgLen-4)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+
+	msg := &message.Msg{}
+	if err := msg.Deserialize(bytes.NewBuffer(b)); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return msg, nil
+}
+
+// FetchPrevHash asks the node at addr for a recent frozen block hash that
+// txes can be signed with
+func FetchPrevHash(addr string) (int64, crypto.Hash, error) {
+	req := message.New(int(message.PrevHashRequest))
+	req.Sign(crypto.GenPrivKey())
+
+	resp, err := Fetch(addr, req)
+	if err != nil {
+		return 0, crypto.Hash{}, err
+	}
+	if resp.Type != message.PrevHashResponse {
+		return 0, crypto.Hash{}, fmt.Errorf("unexpected response type %v", resp.Type)
+	}
+	content := &message.PrevHashResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		return 0, crypto.Hash{}, err
+	}
+	return content.Height, content.Hash, nil
+}
+
+// FetchTimestamp asks the node at addr for its time and adds it to m
+func FetchTimestamp(addr string, m *clock.Median) error {
+	req := message.New(int(message.TimestampRequest))
+	req.Sign(crypto.GenPrivKey())
+
+	sent := m.Base.Now()
+	resp, err := Fetch(addr, req)
+	if err != nil {
+		return err
+	}
+	received := m.Base.Now()
+	if resp.Type != message.TimestampResponse {
+		return fmt.Errorf("unexpected response type %v", resp.Type)
+	}
+	content := &message.TimestampResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		return err
+	}
+	m.Add(addr, time.Unix(0, content.Timestamp), sent, received)
+	return nil
+}
+
+// Ping asks the node at addr for its status and measures the round trip
+func Ping(addr string) (*message.PingResponseContent, time.Duration, error) {
+	req := message.New(int(message.Ping))
+	req.Sign(crypto.GenPrivKey())
+
+	sent := time.Now()
+	resp, err := Fetch(addr, req)
+	if err != nil {
+		return nil, 0, err
+	}
+	rtt := time.Since(sent)
+	if resp.Type != message.PingResponse {
+		return nil, 0, fmt.Errorf("unexpected response type %v", resp.Type)
+	}
+	content := &message.PingResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		return nil, 0, err
+	}
+	return content, rtt, nil
+}
@@ -0,0 +1,178 @@
+package network
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+)
+
+func TestFetch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	privKey := crypto.GenPrivKey()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req, err := ReadMsg(conn)
+		if err != nil || !req.VerifySig() {
+			return
+		}
+		content := &message.TransactionResponseContent{Accepted: true, Message: "ok"}
+		resp := message.New(int(message.TransactionResponse))
+		resp.Content = content.Serialize()
+		resp.Sign(privKey)
+		conn.Write(resp.Serialize())
+	}()
+
+	req := message.New(int(message.Transaction))
+	req.Content = []byte{0x01, 0x02}
+	req.Sign(crypto.GenPrivKey())
+
+	resp, err := Fetch(ln.Addr().String(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != message.TransactionResponse || !resp.VerifySig() {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	content := &message.TransactionResponseContent{}
+	if err := content.Deserialize(resp.Content); err != nil {
+		t.Fatal(err)
+	}
+	if !content.Accepted || content.Message != "ok" {
+		t.Errorf("unexpected response content %+v", content)
+	}
+}
+
+func TestTCPTransport(t *testing.T) {
+	s := NewServer(crypto.GenPrivKey())
+	s.Handle(message.Ping, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		return message.New(int(message.PingResponse))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	defer s.Close()
+
+	req := message.New(int(message.Ping))
+	req.Sign(crypto.GenPrivKey())
+
+	var tr Transport = TCP{}
+	type result struct {
+		resp *message.Msg
+		err  error
+	}
+	results := make(chan result, 1)
+	tr.Send(ln.Addr().String(), req, func(resp *message.Msg, err error) {
+		results <- result{resp, err}
+	})
+
+	select {
+	case r := <-results:
+		if r.err != nil {
+			t.Fatal(r.err)
+		}
+		if r.resp.Type != message.PingResponse || !r.resp.VerifySig() {
+			t.Errorf("unexpected response %+v", r.resp)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response")
+	}
+}
+
+func TestFetchPrevHash(t *testing.T) {
+	s := NewServer(crypto.GenPrivKey())
+	hash := crypto.DoubleSHA256([]byte("block 10"))
+	s.Handle(message.PrevHashRequest, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		content := &message.PrevHashResponseContent{Height: 10, Hash: hash}
+		resp := message.New(int(message.PrevHashResponse))
+		resp.Content = content.Serialize()
+		return resp
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	defer s.Close()
+
+	height, h, err := FetchPrevHash(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != 10 || h != hash {
+		t.Errorf("unexpected prev hash %v %v", height, h)
+	}
+}
+
+func TestFetchTimestamp(t *testing.T) {
+	peerTime := time.Now().Add(time.Hour)
+	s := NewServer(crypto.GenPrivKey())
+	s.Handle(message.TimestampRequest, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		content := &message.TimestampResponseContent{Timestamp: peerTime.UnixNano()}
+		resp := message.New(int(message.TimestampResponse))
+		resp.Content = content.Serialize()
+		return resp
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	defer s.Close()
+
+	m := clock.NewMedian(clock.System{})
+	m.MinPeers = 1
+	if err := FetchTimestamp(ln.Addr().String(), m); err != nil {
+		t.Fatal(err)
+	}
+	if o := m.Offset(); o < 59*time.Minute || o > 61*time.Minute {
+		t.Errorf("unexpected offset %v", o)
+	}
+}
+
+func TestPing(t *testing.T) {
+	s := NewServer(crypto.GenPrivKey())
+	s.Handle(message.Ping, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		time.Sleep(10 * time.Millisecond)
+		content := &message.PingResponseContent{Version: "v1", FrozenEdge: 7, Uptime: int64(time.Hour)}
+		resp := message.New(int(message.PingResponse))
+		resp.Content = content.Serialize()
+		return resp
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	defer s.Close()
+
+	content, rtt, err := Ping(ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content.Version != "v1" || content.FrozenEdge != 7 || content.Uptime != int64(time.Hour) {
+		t.Errorf("unexpected response %+v", content)
+	}
+	if rtt < 10*time.Millisecond {
+		t.Errorf("unexpected round trip %v", rtt)
+	}
+}
@@ -0,0 +1,181 @@
+package network
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/blacklist"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/metrics"
+)
+
+// HandlerFunc handles a message with a valid signature. The returned
+// message, if any, is signed by the server and sent back. Handlers must be
+// safe for concurrent use.
+type HandlerFunc func(msg *message.Msg, addr net.Addr) *message.Msg
+
+var ErrServerClosed = errors.New("network: server closed")
+
+// Server accepts messages over TCP, one message and response per
+// connection as nyzoVerifier does
+type Server struct {
+	privKey crypto.PrivateKey
+
+	mu        sync.RWMutex
+	handlers  map[message.MsgType]HandlerFunc
+	blacklist *blacklist.List
+	ln        net.Listener
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func NewServer(privKey crypto.PrivateKey) *Server {
+	return &Server{
+		privKey:  privKey,
+		handlers: make(map[message.MsgType]HandlerFunc),
+	}
+}
+
+func (s *Server) Handle(msgType message.MsgType, h HandlerFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handlers[msgType] = h
+}
+
+// SetBlacklist drops the connections of banned peers and reports the
+// misbehaving ones to l
+func (s *Server) SetBlacklist(l *blacklist.List) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blacklist = l
+}
+
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts connections until Close is called, it always returns a
+// non-nil error
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return ErrServerClosed
+	}
+	s.ln = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.RLock()
+			closed := s.closed
+			s.mu.RUnlock()
+			if closed {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		// Close may be waiting already, so the connection is only added
+		// while the server is open
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops accepting connections and waits for the in-flight ones
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.ln != nil {
+		err = s.ln.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(DefaultTimeout))
+
+	s.mu.RLock()
+	bl := s.blacklist
+	s.mu.RUnlock()
+	ip := blacklist.IP(conn.RemoteAddr())
+	if bl != nil && !bl.Received(ip) {
+		return
+	}
+
+	msg, err := ReadMsg(conn)
+	if err != nil {
+		if bl != nil && errors.Is(err, ErrMalformed) {
+			bl.Report(ip, crypto.PublicKey{}, blacklist.MalformedMessage)
+		}
+		return
+	}
+	// only the ip is reported, anyone can claim an identifier without a
+	// valid signature
+	if !msg.VerifySig() {
+		if bl != nil {
+			bl.Report(ip, crypto.PublicKey{}, blacklist.InvalidSignature)
+		}
+		return
+	}
+	if bl != nil && bl.BannedID(msg.ID) {
+		return
+	}
+	metrics.MessagesReceived.WithLabelValues(msg.Type.String()).Inc()
+
+	resp := s.Dispatch(msg, conn.RemoteAddr())
+	if resp == nil {
+		return
+	}
+	if _, err := conn.Write(resp.Serialize()); err == nil {
+		metrics.MessagesSent.WithLabelValues(resp.Type.String()).Inc()
+	}
+}
+
+// Dispatch passes msg to its handler and signs the response
+func (s *Server) Dispatch(msg *message.Msg, addr net.Addr) *message.Msg {
+	s.mu.RLock()
+	h, ok := s.handlers[msg.Type]
+	s.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	resp := h(msg, addr)
+	if resp == nil {
+		return nil
+	}
+	resp.Sign(s.privKey)
+	return resp
+}
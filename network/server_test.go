@@ -0,0 +1,157 @@
+package network
+
+import (
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/blacklist"
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files/filestest"
+	"github.com/qqvv/go-nyzo/message"
+)
+
+func TestServer(t *testing.T) {
+	privKey := crypto.GenPrivKey()
+	s := NewServer(privKey)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var handled int32
+	s.Handle(message.Ping, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		defer atomic.StoreInt32(&handled, 1)
+		close(started)
+		<-release
+		resp := message.New(int(message.PingResponse))
+		resp.Content = msg.Content
+		return resp
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	go func() { served <- s.Serve(ln) }()
+
+	req := message.New(int(message.Ping))
+	req.Content = []byte("hello")
+	req.Sign(crypto.GenPrivKey())
+
+	type result struct {
+		msg *message.Msg
+		err error
+	}
+	fetched := make(chan result)
+	go func() {
+		resp, err := Fetch(ln.Addr().String(), req)
+		fetched <- result{resp, err}
+	}()
+
+	// Close must wait for the in-flight request
+	<-started
+	closed := make(chan struct{})
+	go func() {
+		s.Close()
+		if atomic.LoadInt32(&handled) == 0 {
+			t.Error("Close returned before the in-flight request finished")
+		}
+		close(closed)
+	}()
+	close(release)
+
+	r := <-fetched
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+	if r.msg.Type != message.PingResponse || string(r.msg.Content) != "hello" {
+		t.Errorf("unexpected response %+v", r.msg)
+	}
+	if r.msg.ID != privKey.PubKey() || !r.msg.VerifySig() {
+		t.Error("response is not signed by the server")
+	}
+
+	<-closed
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("expected ErrServerClosed, got %v", err)
+	}
+}
+
+func TestServerInvalidSig(t *testing.T) {
+	s := NewServer(crypto.GenPrivKey())
+	s.Handle(message.Ping, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		return message.New(int(message.PingResponse))
+	})
+
+	req := message.New(int(message.Ping))
+	req.Sign(crypto.GenPrivKey())
+	req.Content = []byte("changed after signing")
+
+	if resp := s.Dispatch(req, nil); resp == nil {
+		t.Error("expected Dispatch to respond, signatures are checked on connections")
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	defer s.Close()
+
+	if _, err := Fetch(ln.Addr().String(), req); err == nil {
+		t.Error("expected no response to a message with an invalid signature")
+	}
+}
+
+func TestServerBlacklist(t *testing.T) {
+	filestest.SetDataDir(t)
+	l, err := blacklist.Load(blacklist.DefaultFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// no decay of the scores
+	l.Clock = clock.NewManual(time.Now())
+	s := NewServer(crypto.GenPrivKey())
+	s.SetBlacklist(l)
+	s.Handle(message.Ping, func(msg *message.Msg, addr net.Addr) *message.Msg {
+		return message.New(int(message.PingResponse))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	defer s.Close()
+	addr := ln.Addr().String()
+
+	bad := message.New(int(message.Ping))
+	bad.Sign(crypto.GenPrivKey())
+	bad.Content = []byte("changed after signing")
+	Fetch(addr, bad)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte{0, 0, 0, 2})
+	ioutil.ReadAll(conn)
+	conn.Close()
+
+	if status, _ := l.IPStatus("127.0.0.1"); status.Score != 25+10 {
+		t.Errorf("unexpected score %v", status.Score)
+	}
+
+	good := message.New(int(message.Ping))
+	good.Sign(crypto.GenPrivKey())
+	if _, err := Fetch(addr, good); err != nil {
+		t.Fatal(err)
+	}
+	for !l.Report("127.0.0.1", crypto.PublicKey{}, blacklist.Spam) {
+	}
+	if _, err := Fetch(addr, good); err == nil {
+		t.Error("expected no response to a banned ip")
+	}
+}
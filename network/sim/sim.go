@@ -0,0 +1,319 @@
+// Package sim is a simulated network for multi-node tests. Nodes are
+// network.Servers and messages between them are delivered on a virtual
+// clock with the latency, drops and partitions of their links. Everything
+// runs on the goroutine calling Run, so the same seed always gives the
+// same result.
+package sim
+
+import (
+	"bytes"
+	"container/heap"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+var (
+	// ErrTimeout is returned for messages and responses that were lost
+	ErrTimeout = errors.New("sim: timed out")
+	// ErrUnreachable is returned for addresses without a node, like a
+	// refused connection
+	ErrUnreachable = errors.New("sim: unreachable")
+)
+
+// Epoch is the virtual time every Network starts at
+var Epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Link is the behaviour of messages from one node to another
+type Link struct {
+	Latency time.Duration
+	// Jitter adds a random delay of up to Jitter to the latency
+	Jitter time.Duration
+	// Drop is the probability of a message being lost
+	Drop float64
+}
+
+// Addr is the net.Addr handlers see for the sender of a message
+type Addr string
+
+func (a Addr) Network() string { return "sim" }
+func (a Addr) String() string  { return string(a) }
+
+type Stats struct {
+	Sent      int
+	Delivered int
+	Dropped   int
+}
+
+type Network struct {
+	// Timeout is how long a sender waits for a lost message or response
+	Timeout time.Duration
+
+	mu          sync.Mutex
+	now         time.Time
+	seq         uint64
+	events      events
+	rand        *rand.Rand
+	nodes       map[string]*network.Server
+	links       map[[2]string]Link
+	defaultLink Link
+	// partition groups by address, nil if not partitioned
+	groups map[string]int
+	stats  Stats
+}
+
+func New(seed int64) *Network {
+	return &Network{
+		Timeout: network.DefaultTimeout,
+		now:     Epoch,
+		rand:    rand.New(rand.NewSource(seed)),
+		nodes:   make(map[string]*network.Server),
+		links:   make(map[[2]string]Link),
+	}
+}
+
+// Add connects s to the network at addr and returns the transport it
+// sends with
+func (n *Network) Add(addr string, s *network.Server) *Transport {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.nodes[addr] = s
+	return &Transport{n: n, addr: addr}
+}
+
+// Remove disconnects the node at addr, messages already on their way to
+// it time out
+func (n *Network) Remove(addr string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	delete(n.nodes, addr)
+}
+
+// SetLink sets the link from one node to another, the other direction is
+// not changed
+func (n *Network) SetLink(from, to string, l Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.links[[2]string{from, to}] = l
+}
+
+// SetDefaultLink sets the link of all pairs without one of their own
+func (n *Network) SetDefaultLink(l Link) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.defaultLink = l
+}
+
+// Partition splits the network, messages between groups are lost. The
+// nodes not in any group form a group of their own.
+func (n *Network) Partition(groups ...[]string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.groups = make(map[string]int)
+	for i, group := range groups {
+		for _, addr := range group {
+			n.groups[addr] = i + 1
+		}
+	}
+}
+
+// Heal undoes Partition
+func (n *Network) Heal() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.groups = nil
+}
+
+// Now is the virtual time, the Network is a clock.Clock
+func (n *Network) Now() time.Time {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.now
+}
+
+func (n *Network) Stats() Stats {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.stats
+}
+
+// After calls f on the virtual clock once d has passed, right away if d is
+// negative
+func (n *Network) After(d time.Duration, f func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.schedule(d, f)
+}
+
+// Run advances the virtual clock by d and handles everything that happens
+// until then
+func (n *Network) Run(d time.Duration) {
+	n.mu.Lock()
+	end := n.now.Add(d)
+	n.mu.Unlock()
+
+	for n.step(end) {
+	}
+}
+
+// step handles the next event before end, or moves the clock to end
+func (n *Network) step(end time.Time) bool {
+	n.mu.Lock()
+	if len(n.events) == 0 || n.events[0].at.After(end) {
+		n.now = end
+		n.mu.Unlock()
+		return false
+	}
+	e := heap.Pop(&n.events).(*event)
+	n.now = e.at
+	n.mu.Unlock()
+
+	e.f()
+	return true
+}
+
+// schedule must be called with mu held
+func (n *Network) schedule(d time.Duration, f func()) {
+	if d < 0 {
+		d = 0
+	}
+	n.seq++
+	heap.Push(&n.events, &event{at: n.now.Add(d), seq: n.seq, f: f})
+}
+
+// transit returns the delay of a message from one node to another, or
+// false if it is lost. It must be called with mu held.
+func (n *Network) transit(from, to string) (time.Duration, bool) {
+	n.stats.Sent++
+	if n.groups != nil && n.groups[from] != n.groups[to] {
+		n.stats.Dropped++
+		return 0, false
+	}
+
+	l, ok := n.links[[2]string{from, to}]
+	if !ok {
+		l = n.defaultLink
+	}
+	if l.Drop > 0 && n.rand.Float64() < l.Drop {
+		n.stats.Dropped++
+		return 0, false
+	}
+	d := l.Latency
+	if l.Jitter > 0 {
+		d += time.Duration(n.rand.Int63n(int64(l.Jitter)))
+	}
+	n.stats.Delivered++
+	return d, true
+}
+
+// remaining is the rest of the timeout after elapsed
+func (n *Network) remaining(elapsed time.Duration) time.Duration {
+	if elapsed > n.Timeout {
+		return 0
+	}
+	return n.Timeout - elapsed
+}
+
+// Transport sends the messages of one node
+type Transport struct {
+	n    *Network
+	addr string
+}
+
+// Send delivers a copy of msg to addr after the latency of the link, and
+// the response back the same way. done is called on the virtual clock.
+func (t *Transport) Send(addr string, msg *message.Msg, done func(*message.Msg, error)) {
+	if done == nil {
+		done = func(*message.Msg, error) {}
+	}
+	n := t.n
+	b := msg.Serialize()
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.nodes[addr]; !ok {
+		n.schedule(0, func() { done(nil, ErrUnreachable) })
+		return
+	}
+	timeout := func() { done(nil, ErrTimeout) }
+	d, ok := n.transit(t.addr, addr)
+	if !ok {
+		n.schedule(n.Timeout, timeout)
+		return
+	}
+
+	n.schedule(d, func() {
+		n.mu.Lock()
+		s, ok := n.nodes[addr]
+		n.mu.Unlock()
+		if !ok {
+			n.After(n.remaining(d), timeout)
+			return
+		}
+
+		// like Server.serveConn, responses to invalid messages are never
+		// sent and the sender sees the connection close
+		var resp *message.Msg
+		if req, err := network.ReadMsg(bytes.NewReader(b)); err == nil && req.VerifySig() {
+			resp = s.Dispatch(req, Addr(t.addr))
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		back, ok := n.transit(addr, t.addr)
+		if !ok {
+			n.schedule(n.remaining(d), timeout)
+			return
+		}
+		if resp == nil {
+			n.schedule(back, func() { done(nil, io.EOF) })
+			return
+		}
+		rb := resp.Serialize()
+		n.schedule(back, func() {
+			resp, err := network.ReadMsg(bytes.NewReader(rb))
+			done(resp, err)
+		})
+	})
+}
+
+type event struct {
+	at time.Time
+	// seq orders events at the same time by when they were scheduled
+	seq uint64
+	f   func()
+}
+
+type events []*event
+
+func (e events) Len() int { return len(e) }
+func (e events) Less(i, j int) bool {
+	if e[i].at.Equal(e[j].at) {
+		return e[i].seq < e[j].seq
+	}
+	return e[i].at.Before(e[j].at)
+}
+func (e events) Swap(i, j int)       { e[i], e[j] = e[j], e[i] }
+func (e *events) Push(x interface{}) { *e = append(*e, x.(*event)) }
+func (e *events) Pop() interface{} {
+	old := *e
+	x := old[len(old)-1]
+	*e = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,121 @@
+package sim
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+func newPingNode(n *Network, addr string) *Transport {
+	s := network.NewServer(crypto.GenPrivKey())
+	s.Handle(message.Ping, func(msg *message.Msg, from net.Addr) *message.Msg {
+		resp := message.New(int(message.PingResponse))
+		resp.Content = []byte(from.String())
+		return resp
+	})
+	return n.Add(addr, s)
+}
+
+func TestNetwork(t *testing.T) {
+	n := New(1)
+	a := newPingNode(n, "a")
+	newPingNode(n, "b")
+	n.SetLink("a", "b", Link{Latency: 30 * time.Millisecond})
+	n.SetLink("b", "a", Link{Latency: 20 * time.Millisecond})
+
+	type result struct {
+		resp *message.Msg
+		err  error
+		at   time.Duration
+	}
+	send := func(addr string, msg *message.Msg) *result {
+		r := &result{at: -1}
+		start := n.Now()
+		a.Send(addr, msg, func(resp *message.Msg, err error) {
+			r.resp, r.err, r.at = resp, err, n.Now().Sub(start)
+		})
+		n.Run(time.Minute)
+		return r
+	}
+
+	ping := message.New(int(message.Ping))
+	ping.Sign(crypto.GenPrivKey())
+
+	r := send("b", ping)
+	if r.err != nil || r.at != 50*time.Millisecond {
+		t.Fatalf("unexpected round trip %v after %v", r.err, r.at)
+	}
+	if r.resp.Type != message.PingResponse || string(r.resp.Content) != "a" {
+		t.Errorf("unexpected response %+v", r.resp)
+	}
+
+	tests := []struct {
+		setup func()
+		addr  string
+		err   error
+		at    time.Duration
+	}{
+		{func() {}, "c", ErrUnreachable, 0},
+		{func() { n.Partition([]string{"a"}) }, "b", ErrTimeout, n.Timeout},
+		{func() { n.Heal(); n.SetLink("b", "a", Link{Drop: 1}) }, "b", ErrTimeout, n.Timeout},
+		{func() { n.SetLink("b", "a", Link{}); n.Remove("b") }, "b", ErrUnreachable, 0},
+	}
+
+	for i, test := range tests {
+		test.setup()
+		r := send(test.addr, ping)
+		if r.err != test.err || r.at != test.at {
+			t.Errorf("expected %v after %v, got %v after %v (%v)", test.err, test.at, r.err, r.at, i)
+		}
+	}
+
+	// no response to an invalid signature, the connection is just closed
+	newPingNode(n, "b")
+	ping.Content = []byte("changed after signing")
+	if r := send("b", ping); r.err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", r.err)
+	}
+}
+
+func TestNetworkDeterministic(t *testing.T) {
+	run := func() []bool {
+		n := New(42)
+		n.SetDefaultLink(Link{Latency: time.Millisecond, Jitter: 10 * time.Millisecond, Drop: 0.5})
+		a := newPingNode(n, "a")
+		newPingNode(n, "b")
+
+		ping := message.New(int(message.Ping))
+		ping.Sign(crypto.GenPrivKey())
+
+		var received []bool
+		for i := 0; i < 50; i++ {
+			a.Send("b", ping, func(resp *message.Msg, err error) {
+				received = append(received, err == nil)
+			})
+		}
+		n.Run(time.Minute)
+		return received
+	}
+
+	first, second := run(), run()
+	if len(first) != 50 {
+		t.Fatalf("expected 50 results, got %v", len(first))
+	}
+	lost := 0
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("runs with the same seed differ at %v", i)
+		}
+		if !first[i] {
+			lost++
+		}
+	}
+	if lost == 0 || lost == 50 {
+		t.Errorf("unexpected losses %v", lost)
+	}
+}
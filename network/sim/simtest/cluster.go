@@ -0,0 +1,323 @@
+// Package simtest runs a cycle of toy verifiers on the simulated network
+// of network/sim, for tests that need many nodes exchanging blocks and
+// votes. It is not the real consensus.
+package simtest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+	"github.com/qqvv/go-nyzo/network/sim"
+)
+
+// BlockDuration is the time between blocks, as on the mainnet
+const BlockDuration = 7 * time.Second
+
+// Cluster is a cycle of toy verifiers. The verifiers take turns producing
+// empty blocks in cycle order, vote for the first valid block at the
+// height above their frozen edge and freeze a block with votes from more
+// than 3/4 of the cycle. Verifiers that fall behind fetch the frozen blocks
+// from their peers and trust them.
+type Cluster struct {
+	Net       *sim.Network
+	Verifiers []*Verifier
+}
+
+type Verifier struct {
+	Addr    string
+	privKey crypto.PrivateKey
+
+	cluster   *Cluster
+	server    *network.Server
+	transport network.Transport
+
+	frozen []*block.Block
+	// unfrozen blocks by hash, votes by height and voter
+	blocks map[crypto.Hash]*block.Block
+	votes  map[int64]map[crypto.PublicKey]crypto.Hash
+}
+
+// NewCluster starts n verifiers from the same genesis block, the keys and
+// everything else are derived from seed
+func NewCluster(seed int64, n int) *Cluster {
+	c := &Cluster{Net: sim.New(seed)}
+	rnd := rand.New(rand.NewSource(seed))
+
+	for i := 0; i < n; i++ {
+		v := &Verifier{
+			Addr:    fmt.Sprintf("10.0.0.%d:%d", i+1, network.DefaultPort),
+			cluster: c,
+			blocks:  make(map[crypto.Hash]*block.Block),
+			votes:   make(map[int64]map[crypto.PublicKey]crypto.Hash),
+		}
+		rnd.Read(v.privKey[:32])
+		v.privKey.SetPubKey()
+
+		v.server = network.NewServer(v.privKey)
+		v.server.Handle(message.NewBlock, v.handleNewBlock)
+		v.server.Handle(message.BlockVote, v.handleBlockVote)
+		v.server.Handle(message.BlockRequest, v.handleBlockRequest)
+		v.transport = c.Net.Add(v.Addr, v.server)
+		c.Verifiers = append(c.Verifiers, v)
+	}
+
+	genesis := block.NewWithClock(c.Net, 0, sim.Epoch.UnixNano(), crypto.Hash{}, crypto.Hash{})
+	genesis.VerificationTimestamp = sim.Epoch.UnixNano()
+	genesis.Sign(c.Verifiers[0].privKey)
+	for _, v := range c.Verifiers {
+		v.frozen = []*block.Block{genesis}
+		v.scheduleBlock()
+		c.Net.After(BlockDuration, v.tick)
+	}
+	return c
+}
+
+// Run advances the virtual clock by d
+func (c *Cluster) Run(d time.Duration) {
+	c.Net.Run(d)
+}
+
+// Check returns an error if two verifiers froze different blocks at the
+// same height
+func (c *Cluster) Check() error {
+	longest := c.Verifiers[0]
+	for _, v := range c.Verifiers {
+		if len(v.frozen) > len(longest.frozen) {
+			longest = v
+		}
+	}
+	for _, v := range c.Verifiers {
+		for height, bl := range v.frozen {
+			if bl.Hash() != longest.frozen[height].Hash() {
+				return fmt.Errorf("%v and %v froze different blocks at height %v", v.Addr, longest.Addr, height)
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Verifier) ID() crypto.PublicKey {
+	return v.privKey.PubKey()
+}
+
+func (v *Verifier) FrozenEdge() *block.Block {
+	return v.frozen[len(v.frozen)-1]
+}
+
+// Frozen returns the frozen block at height, or nil
+func (v *Verifier) Frozen(height int64) *block.Block {
+	if height < 0 || height >= int64(len(v.frozen)) {
+		return nil
+	}
+	return v.frozen[height]
+}
+
+// Producer is the verifier whose turn it is at height
+func (c *Cluster) Producer(height int64) *Verifier {
+	return c.Verifiers[height%int64(len(c.Verifiers))]
+}
+
+func (c *Cluster) isVerifier(id crypto.PublicKey) bool {
+	for _, v := range c.Verifiers {
+		if v.ID() == id {
+			return true
+		}
+	}
+	return false
+}
+
+// tick sends our last vote again in case it was lost. Without a vote
+// above the frozen edge that is the vote for the frozen edge, which lets
+// verifiers that missed it freeze it or catch up. The producer sends its
+// block again instead.
+func (v *Verifier) tick() {
+	defer v.cluster.Net.After(BlockDuration, v.tick)
+
+	height := v.FrozenEdge().Height + 1
+	vote, voted := v.votes[height][v.ID()]
+	switch {
+	case voted && v.cluster.Producer(height) == v:
+		v.broadcast(message.NewBlock, v.blocks[vote].Serialize())
+	case voted:
+		v.broadcast(message.BlockVote, voteContent(height, vote))
+	case height > 1:
+		edge := v.FrozenEdge()
+		v.broadcast(message.BlockVote, voteContent(edge.Height, edge.Hash()))
+	}
+}
+
+// scheduleBlock produces the block above the frozen edge at the end of its
+// window if it is our turn
+func (v *Verifier) scheduleBlock() {
+	edge := v.FrozenEdge()
+	height := edge.Height + 1
+	if v.cluster.Producer(height) != v {
+		return
+	}
+	end := time.Unix(0, edge.StartTimestamp).Add(2 * BlockDuration)
+	v.cluster.Net.After(end.Sub(v.cluster.Net.Now()), func() {
+		if v.FrozenEdge() != edge {
+			return
+		}
+		bl := block.NewWithClock(v.cluster.Net, height, edge.StartTimestamp+int64(BlockDuration), edge.Hash(), crypto.Hash{})
+		bl.VerificationTimestamp = v.cluster.Net.Now().UnixNano()
+		bl.Sign(v.privKey)
+		v.blocks[bl.Hash()] = bl
+		v.broadcast(message.NewBlock, bl.Serialize())
+		v.vote(bl)
+	})
+}
+
+func (v *Verifier) broadcast(msgType message.MsgType, content []byte) {
+	msg := message.NewWithClock(v.cluster.Net, int(msgType))
+	msg.Content = content
+	msg.Sign(v.privKey)
+	for _, w := range v.cluster.Verifiers {
+		if w != v {
+			v.transport.Send(w.Addr, msg, nil)
+		}
+	}
+}
+
+func (v *Verifier) vote(bl *block.Block) {
+	v.addVote(v.ID(), bl.Height, bl.Hash())
+	v.broadcast(message.BlockVote, voteContent(bl.Height, bl.Hash()))
+	v.freeze()
+}
+
+// addVote keeps the first vote of every voter at a height
+func (v *Verifier) addVote(id crypto.PublicKey, height int64, hash crypto.Hash) {
+	if v.votes[height] == nil {
+		v.votes[height] = make(map[crypto.PublicKey]crypto.Hash)
+	}
+	if _, ok := v.votes[height][id]; !ok {
+		v.votes[height][id] = hash
+	}
+}
+
+// freeze freezes the blocks above the frozen edge that have enough votes
+func (v *Verifier) freeze() {
+	for {
+		height := v.FrozenEdge().Height + 1
+		tally := make(map[crypto.Hash]int)
+		for _, hash := range v.votes[height] {
+			tally[hash]++
+		}
+		var frozen *block.Block
+		for hash, n := range tally {
+			if n*4 > len(v.cluster.Verifiers)*3 && v.blocks[hash] != nil {
+				frozen = v.blocks[hash]
+			}
+		}
+		if frozen == nil {
+			return
+		}
+		v.appendFrozen(frozen)
+	}
+}
+
+func (v *Verifier) appendFrozen(bl *block.Block) {
+	v.frozen = append(v.frozen, bl)
+	for hash, b := range v.blocks {
+		if b.Height <= bl.Height {
+			delete(v.blocks, hash)
+		}
+	}
+	delete(v.votes, bl.Height)
+	v.scheduleBlock()
+}
+
+// extends checks that bl can follow the frozen edge
+func (v *Verifier) extends(bl *block.Block) bool {
+	edge := v.FrozenEdge()
+	return bl.Height == edge.Height+1 &&
+		bl.PrevBlockHash == edge.Hash() &&
+		bl.VerifierID == v.cluster.Producer(bl.Height).ID() &&
+		bl.Validate() == nil
+}
+
+func (v *Verifier) handleNewBlock(msg *message.Msg, addr net.Addr) *message.Msg {
+	bl := &block.Block{}
+	if err := bl.DeserializeBlock(msg.Content); err == nil {
+		if bl.Height > v.FrozenEdge().Height+1 {
+			v.catchUp(addr.String())
+		} else if v.extends(bl) {
+			v.blocks[bl.Hash()] = bl
+			if _, voted := v.votes[bl.Height][v.ID()]; !voted {
+				v.vote(bl)
+			}
+			v.freeze()
+		}
+	}
+	return message.NewWithClock(v.cluster.Net, int(message.NewBlockResponse))
+}
+
+func (v *Verifier) handleBlockVote(msg *message.Msg, addr net.Addr) *message.Msg {
+	if height, hash, err := readVoteContent(msg.Content); err == nil && v.cluster.isVerifier(msg.ID) {
+		next := v.FrozenEdge().Height + 1
+		if height >= next {
+			v.addVote(msg.ID, height, hash)
+		}
+		// the voter is ahead or voted for a block we missed
+		if height > next || height == next && v.blocks[hash] == nil {
+			v.catchUp(addr.String())
+		}
+		v.freeze()
+	}
+	return message.NewWithClock(v.cluster.Net, int(message.BlockVoteResponse))
+}
+
+func (v *Verifier) handleBlockRequest(msg *message.Msg, addr net.Addr) *message.Msg {
+	resp := message.NewWithClock(v.cluster.Net, int(message.BlockResponse))
+	if len(msg.Content) == 8 {
+		if bl := v.Frozen(int64(binary.BigEndian.Uint64(msg.Content))); bl != nil {
+			resp.Content = bl.Serialize()
+		}
+	}
+	return resp
+}
+
+// catchUp fetches the frozen blocks above our frozen edge from addr
+func (v *Verifier) catchUp(addr string) {
+	req := message.NewWithClock(v.cluster.Net, int(message.BlockRequest))
+	req.Content = make([]byte, 8)
+	binary.BigEndian.PutUint64(req.Content, uint64(v.FrozenEdge().Height+1))
+	req.Sign(v.privKey)
+
+	v.transport.Send(addr, req, func(resp *message.Msg, err error) {
+		if err != nil || resp.Type != message.BlockResponse || len(resp.Content) == 0 {
+			return
+		}
+		bl := &block.Block{}
+		if err := bl.DeserializeBlock(resp.Content); err != nil || !v.extends(bl) {
+			return
+		}
+		v.appendFrozen(bl)
+		v.freeze()
+		v.catchUp(addr)
+	})
+}
+
+func voteContent(height int64, hash crypto.Hash) []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, height)
+	binary.Write(buf, binary.BigEndian, hash)
+	return buf.Bytes()
+}
+
+func readVoteContent(b []byte) (int64, crypto.Hash, error) {
+	var hash crypto.Hash
+	if len(b) != 40 {
+		return 0, hash, fmt.Errorf("invalid vote length %v", len(b))
+	}
+	copy(hash[:], b[8:])
+	return int64(binary.BigEndian.Uint64(b)), hash, nil
+}
@@ -0,0 +1,67 @@
+package simtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/network/sim"
+)
+
+func TestCluster(t *testing.T) {
+	t.Parallel()
+	c := NewCluster(7, 5)
+	c.Net.SetDefaultLink(sim.Link{Latency: 50 * time.Millisecond, Jitter: 200 * time.Millisecond, Drop: 0.05})
+
+	// block h is produced at the end of its window, 7s after it starts at
+	// h*7s, lost messages are sent again every 7s
+	c.Run(12 * BlockDuration)
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range c.Verifiers {
+		if v.FrozenEdge().Height < 9 {
+			t.Errorf("%v is behind at %v", v.Addr, v.FrozenEdge().Height)
+		}
+	}
+
+	// 4 of 5 verifiers are more than 3/4 and keep freezing until it is
+	// the turn of the one cut off, which produced the frozen edge
+	edge := c.Verifiers[0].FrozenEdge()
+	isolated, other := c.Producer(edge.Height), c.Producer(edge.Height+1)
+	c.Net.Partition([]string{isolated.Addr})
+	c.Run(10 * BlockDuration)
+	if isolated.FrozenEdge().Height > edge.Height {
+		t.Errorf("isolated verifier kept freezing")
+	}
+	if h := other.FrozenEdge().Height; h != edge.Height+4 {
+		t.Errorf("expected the majority to freeze up to %v, got %v", edge.Height+4, h)
+	}
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Net.Heal()
+	c.Run(20 * BlockDuration)
+	if err := c.Check(); err != nil {
+		t.Fatal(err)
+	}
+	height := c.Verifiers[0].FrozenEdge().Height
+	for _, v := range c.Verifiers {
+		if v.FrozenEdge().Height < height-1 {
+			t.Errorf("%v did not catch up, at %v of %v", v.Addr, v.FrozenEdge().Height, height)
+		}
+	}
+	if height < edge.Height+20 {
+		t.Errorf("expected the chain to continue after healing, frozen edge %v", height)
+	}
+
+	// the same seed freezes the same blocks
+	d := NewCluster(7, 5)
+	d.Net.SetDefaultLink(sim.Link{Latency: 50 * time.Millisecond, Jitter: 200 * time.Millisecond, Drop: 0.05})
+	d.Run(12 * BlockDuration)
+	for height := int64(0); height <= d.Verifiers[0].FrozenEdge().Height; height++ {
+		if c.Verifiers[0].Frozen(height).Hash() != d.Verifiers[0].Frozen(height).Hash() {
+			t.Errorf("clusters with the same seed froze different blocks at %v", height)
+		}
+	}
+}
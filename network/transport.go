@@ -0,0 +1,26 @@
+package network
+
+import (
+	"github.com/qqvv/go-nyzo/message"
+)
+
+// Transport sends messages to other nodes, the messages they send are
+// passed to a Server's Dispatch. done is called with the response or an
+// error, it may be nil if the response is not needed. This is the callback
+// style of nyzoVerifier's Message.fetch, which lets the simulated network
+// in network/sim run every node on a single goroutine.
+type Transport interface {
+	Send(addr string, msg *message.Msg, done func(resp *message.Msg, err error))
+}
+
+// TCP sends each message over a new connection with Fetch
+type TCP struct{}
+
+func (TCP) Send(addr string, msg *message.Msg, done func(*message.Msg, error)) {
+	go func() {
+		resp, err := Fetch(addr, msg)
+		if done != nil {
+			done(resp, err)
+		}
+	}()
+}
@@ -0,0 +1,5 @@
+// Package roundtrip only holds tests, they encode every wire type from
+// fixed keys and compare the result with the vectors in testdata, so
+// encoding changes don't go unnoticed. The vectors are produced by this
+// code, compatibility with nyzoVerifier is checked by package conformance.
+package roundtrip
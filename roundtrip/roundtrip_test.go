@@ -0,0 +1,262 @@
+package roundtrip
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/conformance"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+var update = flag.Bool("update", false, "rewrite the vectors in testdata")
+
+// TestRoundTrip decodes and encodes every vector in testdata again. The
+// vectors are built by generate, so this only catches unintended changes
+// of the encoding, compatibility with nyzoVerifier is checked by the
+// conformance package.
+func TestRoundTrip(t *testing.T) {
+	if *update {
+		writeGenerated(t)
+	}
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no vectors in testdata")
+	}
+	for _, path := range paths {
+		v := readVector(t, path)
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			if err := v.Check(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// TestGenerated makes sure the vectors in testdata are what the current
+// code produces, run with -update after an intended change
+func TestGenerated(t *testing.T) {
+	generated := generate()
+	names := []string{}
+	for name := range generated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		v := readVector(t, filepath.Join("testdata", name))
+		expected, _ := json.Marshal(generated[name])
+		got, _ := json.Marshal(v)
+		if !bytes.Equal(got, expected) {
+			t.Errorf("%v differs from the generated vector, run go test -update", name)
+		}
+	}
+}
+
+func readVector(t *testing.T, path string) *conformance.Vector {
+	v, err := conformance.ReadVector(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func writeGenerated(t *testing.T) {
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, v := range generate() {
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join("testdata", name), append(b, '\n'), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+// the generated vectors use fixed keys and timestamps, ed25519 signatures
+// are deterministic so they are the same on every run
+func generate() map[string]*conformance.Vector {
+	sender := testKey("5158501bdfec36c8ced939639bfdd8893cff773c76703df6f362daa0b9ab1f83")
+	recipient := testKey("69ffffd36aacf751bc7ef25e7b5351d741387868bc021b4056c336ebbcaf686e")
+	verifier := testKey("8c9ed1a6bb4ea0b6f35be9ed6c6b5b1a0f9b86ef98c45e8b1fbc64e7d3f21a07")
+
+	const milli = 1000 * 1000
+	const timestamp = 1554000000000 * milli
+	prevHash := crypto.DoubleSHA256([]byte("block 10"))
+
+	standard := &transaction.Tx{
+		Type:           2,
+		Timestamp:      timestamp,
+		Amount:         1000000,
+		RecipientID:    recipient.PubKey(),
+		PrevHashHeight: 10,
+		PrevHash:       prevHash,
+		SenderData:     []byte("hello nyzo"),
+	}
+	standard.Sign(sender)
+
+	seed := &transaction.Tx{
+		Type:           1,
+		Timestamp:      timestamp + 1000*milli,
+		Amount:         2500,
+		RecipientID:    sender.PubKey(),
+		PrevHashHeight: 10,
+		PrevHash:       prevHash,
+	}
+	seed.Sign(sender)
+
+	maxData := &transaction.Tx{
+		Type:           2,
+		Timestamp:      timestamp + 2000*milli,
+		Amount:         1,
+		RecipientID:    recipient.PubKey(),
+		PrevHashHeight: 10,
+		PrevHash:       prevHash,
+		SenderData:     bytes.Repeat([]byte{0xa5}, transaction.MaxSenderDataLen),
+	}
+	maxData.Sign(sender)
+
+	badSig := *standard
+	badSig.SenderSig[0] ^= 0xff
+
+	coinGen := &transaction.Tx{
+		Type:        0,
+		Timestamp:   timestamp,
+		Amount:      100000000 * 1000000,
+		RecipientID: verifier.PubKey(),
+	}
+
+	genesis := block.New(0, timestamp, crypto.Hash{}, crypto.DoubleSHA256([]byte("balance list 0")))
+	genesis.VerificationTimestamp = timestamp + 20000*milli
+	genesis.Transactions = []*transaction.Tx{coinGen}
+	genesis.Sign(verifier)
+
+	withTxs := block.New(11, timestamp+11*7000*milli, prevHash, crypto.DoubleSHA256([]byte("balance list 11")))
+	withTxs.VerificationTimestamp = withTxs.StartTimestamp + 9000*milli
+	withTxs.Transactions = []*transaction.Tx{standard, seed}
+	withTxs.Sign(verifier)
+
+	genesisList := &balancelist.List{
+		Items: []*balancelist.Item{{ID: verifier.PubKey(), Balance: coinGen.Amount}},
+	}
+	list := &balancelist.List{Height: 20, RolloverFees: 3}
+	for i := 0; i < 9; i++ {
+		list.PrevVerifiers = append(list.PrevVerifiers, crypto.PublicKey(crypto.DoubleSHA256([]byte{byte(i)})))
+	}
+	list.Items = []*balancelist.Item{
+		{ID: sender.PubKey(), Balance: 997497500, BlocksUntilFee: 500},
+		{ID: recipient.PubKey(), Balance: 1000000, BlocksUntilFee: 12},
+		{ID: verifier.PubKey(), Balance: 99999999000000000, BlocksUntilFee: 0},
+	}
+
+	txMsg := &message.Msg{Timestamp: timestamp, Type: message.Transaction, Content: standard.Serialize()}
+	txMsg.Sign(sender)
+
+	txResponse := &message.Msg{
+		Timestamp: timestamp + 5*milli,
+		Type:      message.TransactionResponse,
+		Content: (&message.TransactionResponseContent{
+			Accepted: true,
+			Message:  "transaction accepted",
+		}).Serialize(),
+	}
+	txResponse.Sign(verifier)
+
+	prevHashResponse := &message.Msg{
+		Timestamp: timestamp + 5*milli,
+		Type:      message.PrevHashResponse,
+		Content:   (&message.PrevHashResponseContent{Height: 10, Hash: prevHash}).Serialize(),
+	}
+	prevHashResponse.Sign(verifier)
+
+	txVector := func(description string, tx *transaction.Tx, sigValid bool) *conformance.Vector {
+		return &conformance.Vector{
+			Description: description,
+			Kind:        "tx",
+			Hex:         hex.EncodeToString(tx.Serialize()),
+			Hash:        conformance.HashString(tx.Hash()),
+			PrevHash:    conformance.HashString(tx.PrevHash),
+			SigValid:    boolPtr(sigValid),
+		}
+	}
+	blockVector := func(description string, bl *block.Block) *conformance.Vector {
+		return &conformance.Vector{
+			Description: description,
+			Kind:        "block",
+			Hex:         hex.EncodeToString(bl.Serialize()),
+			Hash:        conformance.HashString(bl.Hash()),
+			SigValid:    boolPtr(true),
+		}
+	}
+	listVector := func(description string, list *balancelist.List) *conformance.Vector {
+		return &conformance.Vector{
+			Description: description,
+			Kind:        "balancelist",
+			Hex:         hex.EncodeToString(list.Serialize()),
+			Hash:        conformance.HashString(crypto.DoubleSHA256(list.Serialize())),
+		}
+	}
+	msgVector := func(description string, msg *message.Msg) *conformance.Vector {
+		return &conformance.Vector{
+			Description: description,
+			Kind:        "message",
+			Hex:         hex.EncodeToString(msg.Serialize()),
+			SigValid:    boolPtr(true),
+		}
+	}
+
+	coinGenVector := &conformance.Vector{
+		Description: "coin generation tx, only type, timestamp, amount and recipient are serialized",
+		Kind:        "tx",
+		Hex:         hex.EncodeToString(coinGen.Serialize()),
+		Hash:        conformance.HashString(coinGen.Hash()),
+	}
+
+	vectors := map[string]*conformance.Vector{
+		"tx_standard.json":                txVector("standard tx with text sender data", standard, true),
+		"tx_seed.json":                    txVector("seed tx, sender and recipient are the same", seed, true),
+		"tx_max_sender_data.json":         txVector("standard tx with 32 bytes of binary sender data", maxData, true),
+		"tx_bad_signature.json":           txVector("standard tx with the first signature byte flipped", &badSig, false),
+		"tx_coin_generation.json":         coinGenVector,
+		"block_genesis.json":              blockVector("genesis block with the coin generation tx", genesis),
+		"block_txs.json":                  blockVector("block with a standard and a seed tx", withTxs),
+		"balancelist_genesis.json":        listVector("balance list at height 0, no previous verifiers", genesisList),
+		"balancelist_verifiers.json":      listVector("balance list with 9 previous verifiers and rollover fees", list),
+		"message_transaction.json":        msgVector("transaction message carrying the standard tx", txMsg),
+		"message_tx_response.json":        msgVector("accepted transaction response", txResponse),
+		"message_prev_hash_response.json": msgVector("prev hash response for height 10", prevHashResponse),
+	}
+	for _, v := range vectors {
+		v.Source = conformance.SourceGenerated
+	}
+	return vectors
+}
+
+func testKey(seed string) crypto.PrivateKey {
+	privKey, err := crypto.ParseSeed(seed)
+	if err != nil {
+		panic(err)
+	}
+	return privKey
+}
@@ -0,0 +1,357 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/db"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+// JSON-RPC 2.0 error codes
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// application error codes, outside the range reserved by JSON-RPC
+const (
+	// NotFound is returned for blocks and txes that don't exist
+	NotFound = 1
+)
+
+const maxBodySize = 1 << 20
+
+type Chain interface {
+	Block(height int64) (*block.Block, error)
+	FrozenEdge() (*block.Block, error)
+	// Transaction returns a frozen tx and the height of its block
+	Transaction(hash crypto.Hash) (*transaction.Tx, int64, error)
+}
+
+type Balances interface {
+	BalanceList() (*balancelist.List, error)
+}
+
+type Pool interface {
+	Add(tx *transaction.Tx) error
+}
+
+type Mesh interface {
+	Verifiers() []crypto.PublicKey
+}
+
+type Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type Response struct {
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// MarshalJSON writes result exactly when there is no error, a null
+// result included, as JSON-RPC 2.0 requires
+func (r *Response) MarshalJSON() ([]byte, error) {
+	if r.Error != nil {
+		return json.Marshal(&struct {
+			Version string          `json:"jsonrpc"`
+			Error   *Error          `json:"error"`
+			ID      json.RawMessage `json:"id"`
+		}{r.Version, r.Error, r.ID})
+	}
+	return json.Marshal(&struct {
+		Version string          `json:"jsonrpc"`
+		Result  interface{}     `json:"result"`
+		ID      json.RawMessage `json:"id"`
+	}{r.Version, r.Result, r.ID})
+}
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error %v: %v", e.Code, e.Message)
+}
+
+type handlerFunc func(params json.RawMessage) (interface{}, error)
+
+type Server struct {
+	Chain    Chain
+	Balances Balances
+	Pool     Pool
+	Mesh     Mesh
+
+	methods map[string]handlerFunc
+}
+
+func NewServer(chain Chain, balances Balances, pool Pool, mesh Mesh) *Server {
+	s := &Server{
+		Chain:    chain,
+		Balances: balances,
+		Pool:     pool,
+		Mesh:     mesh,
+	}
+	s.methods = map[string]handlerFunc{
+		"getBlock":          s.getBlock,
+		"getFrozenEdge":     s.getFrozenEdge,
+		"getBalance":        s.getBalance,
+		"getBalanceList":    s.getBalanceList,
+		"getTransaction":    s.getTransaction,
+		"submitTransaction": s.submitTransaction,
+		"getMesh":           s.getMesh,
+	}
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeJSON(w, errResponse(nil, ParseError, err.Error()))
+		return
+	}
+
+	// a batch is an array of requests, anything else is a single request
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		var reqs []json.RawMessage
+		if err := json.Unmarshal(body, &reqs); err != nil {
+			writeJSON(w, errResponse(nil, ParseError, err.Error()))
+			return
+		}
+		if len(reqs) == 0 {
+			writeJSON(w, errResponse(nil, InvalidRequest, "empty batch"))
+			return
+		}
+		resps := []*Response{}
+		for _, raw := range reqs {
+			if resp := s.handle(raw); resp != nil {
+				resps = append(resps, resp)
+			}
+		}
+		if len(resps) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, resps)
+		return
+	}
+
+	resp := s.handle(body)
+	if resp == nil {
+		// notification
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, resp)
+}
+
+// handle returns nil for notifications (requests without an id)
+func (s *Server) handle(raw []byte) *Response {
+	if !json.Valid(raw) {
+		return errResponse(nil, ParseError, "invalid json")
+	}
+	// valid json that is no request object, like the 1 in [1]
+	req := &Request{}
+	if err := json.Unmarshal(raw, req); err != nil {
+		return errResponse(nil, InvalidRequest, err.Error())
+	}
+	if req.Version != "2.0" || req.Method == "" {
+		return errResponse(req.ID, InvalidRequest, "invalid request")
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		if req.ID == nil {
+			return nil
+		}
+		return errResponse(req.ID, MethodNotFound, "method not found: "+req.Method)
+	}
+
+	result, err := method(req.Params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		if rpcErr, ok := err.(*Error); ok {
+			return errResponse(req.ID, rpcErr.Code, rpcErr.Message)
+		}
+		if errors.Is(err, db.ErrNotFound) {
+			return errResponse(req.ID, NotFound, err.Error())
+		}
+		return errResponse(req.ID, InternalError, err.Error())
+	}
+	return &Response{Version: "2.0", Result: result, ID: req.ID}
+}
+
+func (s *Server) getBlock(params json.RawMessage) (interface{}, error) {
+	if s.Chain == nil {
+		return nil, unavailable("chain")
+	}
+	p := struct {
+		Height *int64 `json:"height"`
+	}{}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Height == nil {
+		return nil, &Error{InvalidParams, "missing height"}
+	}
+	return s.Chain.Block(*p.Height)
+}
+
+func (s *Server) getFrozenEdge(params json.RawMessage) (interface{}, error) {
+	if s.Chain == nil {
+		return nil, unavailable("chain")
+	}
+	return s.Chain.FrozenEdge()
+}
+
+func (s *Server) getBalance(params json.RawMessage) (interface{}, error) {
+	if s.Balances == nil {
+		return nil, unavailable("balance list")
+	}
+	p := struct {
+		ID *crypto.PublicKey `json:"id"`
+	}{}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ID == nil {
+		return nil, &Error{InvalidParams, "missing id"}
+	}
+	id := *p.ID
+
+	list, err := s.Balances.BalanceList()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list.Items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return &balancelist.Item{ID: id}, nil
+}
+
+func (s *Server) getBalanceList(params json.RawMessage) (interface{}, error) {
+	if s.Balances == nil {
+		return nil, unavailable("balance list")
+	}
+	return s.Balances.BalanceList()
+}
+
+func (s *Server) getTransaction(params json.RawMessage) (interface{}, error) {
+	if s.Chain == nil {
+		return nil, unavailable("chain")
+	}
+	p := struct {
+		Hash *crypto.Hash `json:"hash"`
+	}{}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Hash == nil {
+		return nil, &Error{InvalidParams, "missing hash"}
+	}
+
+	tx, height, err := s.Chain.Transaction(*p.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		Height int64           `json:"height"`
+		Tx     *transaction.Tx `json:"transaction"`
+	}{height, tx}, nil
+}
+
+func (s *Server) submitTransaction(params json.RawMessage) (interface{}, error) {
+	if s.Pool == nil {
+		return nil, unavailable("transaction pool")
+	}
+	// the tx is passed in its serialized form
+	p := struct {
+		Tx string `json:"tx"`
+	}{}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(p.Tx)
+	if err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+
+	tx := &transaction.Tx{}
+	if err := tx.Deserialize(b); err != nil {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+	if valid, err := tx.Validate(); !valid {
+		return nil, &Error{InvalidParams, err.Error()}
+	}
+	if err := s.Pool.Add(tx); err != nil {
+		return nil, err
+	}
+
+	return tx.Hash(), nil
+}
+
+func (s *Server) getMesh(params json.RawMessage) (interface{}, error) {
+	if s.Mesh == nil {
+		return nil, unavailable("mesh")
+	}
+	return s.Mesh.Verifiers(), nil
+}
+
+func parseParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &Error{InvalidParams, err.Error()}
+	}
+	return nil
+}
+
+func unavailable(what string) error {
+	return &Error{InternalError, what + " not available"}
+}
+
+func errResponse(id json.RawMessage, code int, msg string) *Response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &Response{
+		Version: "2.0",
+		Error:   &Error{Code: code, Message: msg},
+		ID:      id,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
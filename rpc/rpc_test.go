@@ -0,0 +1,277 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/balancelist"
+	"github.com/qqvv/go-nyzo/block"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/db"
+	"github.com/qqvv/go-nyzo/transaction"
+)
+
+type testChain struct {
+	blocks []*block.Block
+}
+
+func (c *testChain) Block(height int64) (*block.Block, error) {
+	if height < 0 || height >= int64(len(c.blocks)) {
+		return nil, fmt.Errorf("block %v: %w", height, db.ErrNotFound)
+	}
+	return c.blocks[height], nil
+}
+
+func (c *testChain) FrozenEdge() (*block.Block, error) {
+	return c.blocks[len(c.blocks)-1], nil
+}
+
+func (c *testChain) Transaction(hash crypto.Hash) (*transaction.Tx, int64, error) {
+	for _, bl := range c.blocks {
+		for _, tx := range bl.Transactions {
+			if tx.Hash() == hash {
+				return tx, bl.Height, nil
+			}
+		}
+	}
+	return nil, 0, fmt.Errorf("tx: %w", db.ErrNotFound)
+}
+
+type testBalances struct {
+	list *balancelist.List
+}
+
+func (b *testBalances) BalanceList() (*balancelist.List, error) {
+	return b.list, nil
+}
+
+type testPool struct {
+	txs []*transaction.Tx
+}
+
+func (p *testPool) Add(tx *transaction.Tx) error {
+	p.txs = append(p.txs, tx)
+	return nil
+}
+
+type testMesh struct{}
+
+func (m testMesh) Verifiers() []crypto.PublicKey {
+	return []crypto.PublicKey{{0x01}, {0x02}}
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *testChain, *testPool) {
+	privKey := crypto.GenPrivKey()
+	tx := transaction.NewStandard(5, crypto.PublicKey{0xaa}, []byte("hi"))
+	tx.Sign(privKey)
+
+	chain := &testChain{}
+	for i := int64(0); i < 3; i++ {
+		bl := block.New(i, 0, crypto.Hash{}, crypto.Hash{})
+		if i == 2 {
+			bl.Transactions = []*transaction.Tx{tx}
+		}
+		bl.Sign(privKey)
+		chain.blocks = append(chain.blocks, bl)
+	}
+
+	balances := &testBalances{list: &balancelist.List{
+		Height: 2,
+		Items: []*balancelist.Item{
+			{ID: crypto.PublicKey{0xaa}, Balance: 5},
+		},
+	}}
+	pool := &testPool{}
+
+	srv := httptest.NewServer(NewServer(chain, balances, pool, testMesh{}))
+	t.Cleanup(srv.Close)
+	return srv, chain, pool
+}
+
+func call(t *testing.T, url, method string, params interface{}) *Response {
+	body := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"id":      1,
+	}
+	if params != nil {
+		body["params"] = params
+	}
+	b, _ := json.Marshal(body)
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	r := &Response{}
+	if err := json.NewDecoder(resp.Body).Decode(r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMethods(t *testing.T) {
+	srv, chain, pool := newTestServer(t)
+	tx := chain.blocks[2].Transactions[0]
+	hash := tx.Hash()
+
+	newTx := transaction.NewStandard(1, crypto.PublicKey{0xbb}, nil)
+	newTx.Sign(crypto.GenPrivKey())
+
+	tests := []struct {
+		method string
+		params interface{}
+		code   int
+	}{
+		{"getBlock", map[string]int64{"height": 1}, 0},
+		{"getBlock", map[string]int64{"height": 10}, NotFound},
+		{"getBlock", nil, InvalidParams},
+		{"getFrozenEdge", nil, 0},
+		{"getBalance", map[string]string{"id": crypto.PublicKey{0xaa}.StringWithDashes()}, 0},
+		{"getBalance", map[string]string{"id": "xyz"}, InvalidParams},
+		{"getBalanceList", nil, 0},
+		{"getTransaction", map[string]string{"hash": hex.EncodeToString(hash[:])}, 0},
+		{"getTransaction", map[string]string{"hash": "00"}, InvalidParams},
+		{"getTransaction", map[string]string{"hash": hex.EncodeToString(make([]byte, 32))}, NotFound},
+		{"submitTransaction", map[string]string{"tx": hex.EncodeToString(newTx.Serialize())}, 0},
+		{"submitTransaction", map[string]string{"tx": "02"}, InvalidParams},
+		{"getMesh", nil, 0},
+		{"doesNotExist", nil, MethodNotFound},
+	}
+
+	for i, test := range tests {
+		resp := call(t, srv.URL, test.method, test.params)
+		if test.code == 0 && resp.Error != nil {
+			t.Errorf("%v (%v) failed: %v", test.method, i, resp.Error)
+		}
+		if test.code != 0 && (resp.Error == nil || resp.Error.Code != test.code) {
+			t.Errorf("%v (%v): expected error code %v, got %+v", test.method, i, test.code, resp.Error)
+		}
+	}
+
+	if len(pool.txs) != 1 {
+		t.Errorf("expected 1 tx in pool, got %v", len(pool.txs))
+	}
+}
+
+func TestGetBalance(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	resp := call(t, srv.URL, "getBalance", map[string]string{"id": crypto.PublicKey{0xaa}.String()})
+	if resp.Error != nil {
+		t.Fatal(resp.Error)
+	}
+	item := resp.Result.(map[string]interface{})
+	if item["balance"].(float64) != 5 {
+		t.Errorf("expected balance 5, got %v", item["balance"])
+	}
+}
+
+func TestBatch(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	body := `[
+		{"jsonrpc": "2.0", "method": "getFrozenEdge", "id": 1},
+		{"jsonrpc": "2.0", "method": "getMesh"},
+		{"jsonrpc": "2.0", "method": "getMesh", "id": 2}
+	]`
+	resp, err := http.Post(srv.URL, "application/json", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	resps := []*Response{}
+	if err := json.NewDecoder(resp.Body).Decode(&resps); err != nil {
+		t.Fatal(err)
+	}
+	// the notification gets no response
+	if len(resps) != 2 {
+		t.Errorf("expected 2 responses, got %v", len(resps))
+	}
+}
+
+func TestInvalidRequests(t *testing.T) {
+	srv, _, _ := newTestServer(t)
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{`, ParseError},
+		{`{"jsonrpc": "1.0", "method": "getMesh", "id": 1}`, InvalidRequest},
+		{`[]`, InvalidRequest},
+		{`1`, InvalidRequest},
+		{`{"jsonrpc": "2.0", "method": 1, "id": 1}`, InvalidRequest},
+	}
+
+	for i, test := range tests {
+		resp, err := http.Post(srv.URL, "application/json", bytes.NewReader([]byte(test.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := &Response{}
+		err = json.NewDecoder(resp.Body).Decode(r)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Error == nil || r.Error.Code != test.code {
+			t.Errorf("expected error code %v (%v), got %+v", test.code, i, r.Error)
+		}
+	}
+
+	// a batch element that is no object is an invalid request, not a
+	// parse error
+	resp, err := http.Post(srv.URL, "application/json", bytes.NewReader([]byte(`[1]`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resps := []*Response{}
+	err = json.NewDecoder(resp.Body).Decode(&resps)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resps) != 1 || resps[0].Error == nil || resps[0].Error.Code != InvalidRequest {
+		t.Errorf("expected one invalid request error, got %+v", resps)
+	}
+
+	resp, err = http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected status %v, got %v", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestResponseJSON(t *testing.T) {
+	tests := []struct {
+		resp     *Response
+		expected string
+	}{
+		{&Response{Version: "2.0", Result: 0, ID: json.RawMessage("1")}, `{"jsonrpc":"2.0","result":0,"id":1}`},
+		{&Response{Version: "2.0", Result: []string{}, ID: json.RawMessage("1")}, `{"jsonrpc":"2.0","result":[],"id":1}`},
+		{&Response{Version: "2.0", ID: json.RawMessage(`"a"`)}, `{"jsonrpc":"2.0","result":null,"id":"a"}`},
+		{errResponse(nil, ParseError, "bad"), `{"jsonrpc":"2.0","error":{"code":-32700,"message":"bad"},"id":null}`},
+	}
+
+	for i, test := range tests {
+		b, err := json.Marshal(test.resp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != test.expected {
+			t.Errorf("expected %s, got %s (%v)", test.expected, b, i)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package timesync keeps a clock.Median in line with the network by
+// asking peers for their time with TimestampRequests.
+package timesync
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/logging"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/metrics"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+const (
+	DefaultInterval = 5 * time.Minute
+	// DefaultMaxDrift is well below the 7s of a block
+	DefaultMaxDrift = time.Second
+)
+
+var log = logging.Logger("timesync")
+
+type Syncer struct {
+	Clock *clock.Median
+	// Peers returns the addresses to ask, it is called for every round
+	Peers    func() []string
+	Interval time.Duration
+	// MaxDrift is how far the local clock may be off before every round
+	// logs a warning
+	MaxDrift time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+	// started is set by the first Run, or by Stop if Run never started
+	mu      sync.Mutex
+	started bool
+}
+
+func New(m *clock.Median, peers func() []string) *Syncer {
+	return &Syncer{
+		Clock:    m,
+		Peers:    peers,
+		Interval: DefaultInterval,
+		MaxDrift: DefaultMaxDrift,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Sync asks all peers for their time at once. Peers that don't answer or
+// are gone from Peers are dropped from the median.
+func (s *Syncer) Sync() {
+	peers := s.Peers()
+	s.Clock.Retain(peers)
+
+	var wg sync.WaitGroup
+	for _, addr := range peers {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			if err := network.FetchTimestamp(addr, s.Clock); err != nil {
+				log.Debug("fetching timestamp failed", "addr", addr, "err", err)
+				s.Clock.Remove(addr)
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	offset := s.Offset()
+	metrics.ClockOffset.Set(offset.Seconds())
+	if offset > s.MaxDrift || offset < -s.MaxDrift {
+		log.Warn("local clock is off, using the time of the peers",
+			"offset", offset, "peers", s.Clock.Peers())
+	} else {
+		log.Debug("synced time", "offset", offset, "peers", s.Clock.Peers())
+	}
+}
+
+// Offset is how far the local clock is behind the network
+func (s *Syncer) Offset() time.Duration {
+	return s.Clock.Offset()
+}
+
+// Run syncs right away and then every Interval until Stop is called. It
+// returns right away if it was called before or Stop was.
+func (s *Syncer) Run() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.mu.Unlock()
+	defer close(s.done)
+
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for {
+		s.Sync()
+		select {
+		case <-t.C:
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops Run and waits for it to return, it may be called more than
+// once and without Run
+func (s *Syncer) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	s.mu.Lock()
+	if !s.started {
+		s.started = true
+		close(s.done)
+	}
+	s.mu.Unlock()
+	<-s.done
+}
+
+// HandleTimestampRequest answers with the local time, not the adjusted
+// one, so nodes don't just follow each other
+func (s *Syncer) HandleTimestampRequest(msg *message.Msg, addr net.Addr) *message.Msg {
+	content := &message.TimestampResponseContent{Timestamp: s.Clock.Base.Now().UnixNano()}
+	resp := message.NewWithClock(s.Clock, int(message.TimestampResponse))
+	resp.Content = content.Serialize()
+	return resp
+}
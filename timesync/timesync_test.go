@@ -0,0 +1,93 @@
+package timesync
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/message"
+	"github.com/qqvv/go-nyzo/network"
+)
+
+var epoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// startPeer serves the time of a clock offset from epoch
+func startPeer(t *testing.T, offset time.Duration) string {
+	peer := New(clock.NewMedian(clock.NewManual(epoch.Add(offset))), nil)
+	s := network.NewServer(crypto.GenPrivKey())
+	s.Handle(message.TimestampRequest, peer.HandleTimestampRequest)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(ln)
+	t.Cleanup(func() { s.Close() })
+	return ln.Addr().String()
+}
+
+func TestSync(t *testing.T) {
+	peers := []string{
+		startPeer(t, 2*time.Second),
+		startPeer(t, 2*time.Second+10*time.Millisecond),
+		startPeer(t, 2*time.Second-20*time.Millisecond),
+		// way off, ignored
+		startPeer(t, -time.Hour),
+		// not listening
+		"127.0.0.1:1",
+	}
+
+	m := clock.NewMedian(clock.NewManual(epoch))
+	s := New(m, func() []string { return peers })
+	s.Sync()
+
+	if m.Peers() != 4 {
+		t.Errorf("expected 4 peers, got %v", m.Peers())
+	}
+	if o := s.Offset(); o != 2*time.Second {
+		t.Errorf("unexpected offset %v", o)
+	}
+	if now := m.Now(); !now.Equal(epoch.Add(2 * time.Second)) {
+		t.Errorf("unexpected adjusted time %v", now)
+	}
+
+	// peers that are gone drop out
+	peers = peers[:2]
+	s.Sync()
+	if m.Peers() != 2 {
+		t.Errorf("expected 2 peers, got %v", m.Peers())
+	}
+}
+
+func TestRun(t *testing.T) {
+	m := clock.NewMedian(clock.System{})
+	synced := make(chan struct{}, 10)
+	s := New(m, func() []string {
+		select {
+		case synced <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	s.Interval = time.Millisecond
+
+	go s.Run()
+	<-synced
+	<-synced
+	s.Stop()
+	s.Stop()
+}
+
+func TestStop(t *testing.T) {
+	s := New(clock.NewMedian(clock.System{}), func() []string {
+		t.Error("expected no sync after Stop")
+		return nil
+	})
+
+	// without Run, and more than once
+	s.Stop()
+	s.Stop()
+	s.Run()
+}
@@ -0,0 +1,76 @@
+package transaction
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+// PrefilledData is what a pre_ string holds: everything a wallet needs to
+// prefill a tx to the recipient. Amount is optional (0 if not set).
+type PrefilledData struct {
+	RecipientID crypto.PublicKey `json:"recipientId"`
+	SenderData  []byte           `json:"senderData"`
+	Amount      int64            `json:"amount"`
+}
+
+func (tx *Tx) NyzoString() string {
+	return crypto.EncodeNyzoString(crypto.PrefixTransaction, tx.Serialize())
+}
+
+func FromNyzoString(s string) (*Tx, error) {
+	b, err := crypto.DecodeNyzoStringPrefix(s, crypto.PrefixTransaction)
+	if err != nil {
+		return nil, err
+	}
+	tx := &Tx{}
+	if err := tx.Deserialize(b); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+func (data *PrefilledData) NyzoString() string {
+	buf := new(bytes.Buffer)
+	buf.Write(data.RecipientID[:])
+
+	senderData := data.SenderData
+	if len(senderData) > 32 {
+		senderData = senderData[:32]
+	}
+	buf.WriteByte(byte(len(senderData)))
+	buf.Write(senderData)
+
+	if data.Amount > 0 {
+		binary.Write(buf, binary.BigEndian, data.Amount)
+	}
+
+	return crypto.EncodeNyzoString(crypto.PrefixPrefilledData, buf.Bytes())
+}
+
+func ParsePrefilledData(s string) (*PrefilledData, error) {
+	b, err := crypto.DecodeNyzoStringPrefix(s, crypto.PrefixPrefilledData)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 33 {
+		return nil, fmt.Errorf("prefilled data too short")
+	}
+
+	data := &PrefilledData{}
+	copy(data.RecipientID[:], b)
+
+	dataLen := int(b[32])
+	if dataLen > 32 || len(b) < 33+dataLen {
+		return nil, fmt.Errorf("invalid prefilled sender data length: %v", dataLen)
+	}
+	data.SenderData = append([]byte{}, b[33:33+dataLen]...)
+
+	if rest := b[33+dataLen:]; len(rest) >= 8 {
+		data.Amount = int64(binary.BigEndian.Uint64(rest))
+	}
+
+	return data, nil
+}
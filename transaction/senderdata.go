@@ -0,0 +1,87 @@
+package transaction
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+const MaxSenderDataLen = 32
+
+var ErrSenderDataTooLong = fmt.Errorf("sender data is longer than %v bytes", MaxSenderDataLen)
+
+// SenderDataFromText returns the UTF-8 bytes of s
+func SenderDataFromText(s string) ([]byte, error) {
+	if len(s) > MaxSenderDataLen {
+		return nil, ErrSenderDataTooLong
+	}
+	return []byte(s), nil
+}
+
+// SenderDataFromHex decodes s, dashes are ignored
+func SenderDataFromHex(s string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > MaxSenderDataLen {
+		return nil, ErrSenderDataTooLong
+	}
+	return b, nil
+}
+
+// ParseSenderData reads sender data as rendered by SenderDataString: hex in
+// X(...) or plain text otherwise
+func ParseSenderData(s string) ([]byte, error) {
+	if strings.HasPrefix(s, "X(") && strings.HasSuffix(s, ")") {
+		return SenderDataFromHex(s[2 : len(s)-1])
+	}
+	return SenderDataFromText(s)
+}
+
+// SenderDataString renders sender data for display, as text if it is
+// printable UTF-8 and as X(hex) otherwise
+func SenderDataString(data []byte) string {
+	if isPrintable(data) {
+		return string(data)
+	}
+	return "X(" + hex.EncodeToString(data) + ")"
+}
+
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	s := string(data)
+	// text that looks like the hex format would be parsed as hex
+	if strings.HasPrefix(s, "X(") && strings.HasSuffix(s, ")") {
+		return false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetSenderData sets the sender data, failing if it is too long
+func (tx *Tx) SetSenderData(data []byte) error {
+	if len(data) > MaxSenderDataLen {
+		return ErrSenderDataTooLong
+	}
+	tx.SenderData = data
+	return nil
+}
+
+// NewStandardStrict is like NewStandard but fails if data is too long
+func NewStandardStrict(amount int64, recipientID crypto.PublicKey, data []byte) (*Tx, error) {
+	if len(data) > MaxSenderDataLen {
+		return nil, ErrSenderDataTooLong
+	}
+	return NewStandard(amount, recipientID, data), nil
+}
@@ -4,11 +4,26 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
-	"time"
+	"math"
 
+	"github.com/qqvv/go-nyzo/clock"
 	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/logging"
 )
 
+// maxTimestamp is the largest timestamp in milli that fits in nano
+const maxTimestamp = math.MaxInt64 / 1000 / 1000
+
+// DefaultSigCacheSize is about 10MB worth of cached results
+const DefaultSigCacheSize = 50000
+
+// SigCache caches the signature verification of Validate and ValidateAll,
+// the same tx is usually validated in the pool, in blocks and when frozen.
+// Set it to nil to disable caching.
+var SigCache = crypto.NewSigCache(DefaultSigCacheSize)
+
+var log = logging.Logger("transaction")
+
 type Tx struct {
 	Type           byte             `json:"type"` // 0=coingeneration, 1=seed, 2=standard
 	Timestamp      int64            `json:"timestamp"`
@@ -31,6 +46,59 @@ func (tx *Tx) Validate() (bool, error) {
 	// (5) the sender and receiver are different
 	// (6) the block for the specified timestamp is still open for processing
 
+	return tx.validate(func() bool {
+		return SigCache.Verify(tx.SenderID, tx.ForSigning(), tx.SenderSig)
+	})
+}
+
+// ValidateAll validates txes like Validate but verifies the signatures
+// that are not cached in parallel first. The returned errors are nil for
+// valid txes.
+func ValidateAll(txs []*Tx) []error {
+	validSigs := make([]bool, len(txs))
+
+	var keys []crypto.Hash
+	var items []crypto.VerifyItem
+	var indexes []int
+	for i, tx := range txs {
+		msg := tx.ForSigning()
+		if SigCache != nil {
+			key := crypto.SigCacheKey(tx.SenderID, msg, tx.SenderSig)
+			if valid, ok := SigCache.Get(key); ok {
+				validSigs[i] = valid
+				continue
+			}
+			keys = append(keys, key)
+		}
+		items = append(items, crypto.VerifyItem{
+			PubKey: tx.SenderID,
+			Msg:    msg,
+			Sig:    tx.SenderSig,
+		})
+		indexes = append(indexes, i)
+	}
+
+	invalid := crypto.BatchVerify(items)
+	for j, i := range indexes {
+		valid := len(invalid) == 0 || invalid[0] != j
+		if !valid {
+			invalid = invalid[1:]
+		}
+		validSigs[i] = valid
+		if SigCache != nil {
+			SigCache.Add(keys[j], valid)
+		}
+	}
+
+	errs := make([]error, len(txs))
+	for i, tx := range txs {
+		valid := validSigs[i]
+		_, errs[i] = tx.validate(func() bool { return valid })
+	}
+	return errs
+}
+
+func (tx *Tx) validate(verifySig func() bool) (bool, error) {
 	var valid = true
 	var err error
 
@@ -39,11 +107,16 @@ func (tx *Tx) Validate() (bool, error) {
 		err = fmt.Errorf("only seed (1) and standard (2) txes are valid after block 0")
 	}
 
-	if valid && !tx.SenderID.Verify(tx.ForSigning(), tx.SenderSig) {
+	if valid && !verifySig() {
 		valid = false
 		err = fmt.Errorf("signature is not valid")
 	}
 
+	if valid && len(tx.SenderData) > MaxSenderDataLen {
+		valid = false
+		err = ErrSenderDataTooLong
+	}
+
 	if valid && tx.Amount < 1 {
 		valid = false
 		err = fmt.Errorf("tx amount must be at least µ1")
@@ -63,6 +136,14 @@ func (tx *Tx) Validate() (bool, error) {
 		}
 	}
 
+	if !valid {
+		log.Debug("invalid tx",
+			"type", tx.Type,
+			"sender", tx.SenderID.StringCompact(),
+			"recipient", tx.RecipientID.StringCompact(),
+			"amount", tx.Amount,
+			"err", err)
+	}
 	return valid, err
 }
 
@@ -74,6 +155,12 @@ func (tx *Tx) Serialize() []byte {
 	binary.Write(buf, binary.BigEndian, tx.Timestamp/1000/1000) // to milli
 	binary.Write(buf, binary.BigEndian, tx.Amount)
 	binary.Write(buf, binary.BigEndian, tx.RecipientID)
+
+	// Coingeneration transactions don't have the last fields
+	if int(tx.Type) == 0 {
+		return buf.Bytes()
+	}
+
 	binary.Write(buf, binary.BigEndian, tx.PrevHashHeight)
 	binary.Write(buf, binary.BigEndian, tx.SenderID)
 	binary.Write(buf, binary.BigEndian, byte(len(tx.SenderData)))
@@ -112,14 +199,25 @@ func (tx *Tx) Deserialize(i interface{}) error {
 		return fmt.Errorf("cannot deserialize tx from %#v", i)
 	}
 
-	binary.Read(buf, binary.BigEndian, &tx.Type)
+	typ, err := buf.ReadByte()
+	if err != nil {
+		return fmt.Errorf("tx is empty")
+	}
+	tx.Type = typ
 
 	// 0=coingeneration, 1=seed, 2=standard
 	if tx.GetType() > 2 || tx.GetType() < 0 {
 		return fmt.Errorf("unknown tx type: %v", int(tx.Type))
 	}
 
+	// timestamp (8) + amount (8) + recipient pubk (32)
+	if buf.Len() < 48 {
+		return fmt.Errorf("tx is too short")
+	}
 	binary.Read(buf, binary.BigEndian, &tx.Timestamp)
+	if tx.Timestamp > maxTimestamp || tx.Timestamp < -maxTimestamp {
+		return fmt.Errorf("tx timestamp out of range: %v", tx.Timestamp)
+	}
 	tx.Timestamp *= 1000 * 1000 // to nano
 	binary.Read(buf, binary.BigEndian, &tx.Amount)
 	binary.Read(buf, binary.BigEndian, &tx.RecipientID)
@@ -129,16 +227,23 @@ func (tx *Tx) Deserialize(i interface{}) error {
 		return nil
 	}
 
+	// prevhashheight (8) + sender pubk (32) + senderdata len (1)
+	if buf.Len() < 41 {
+		return fmt.Errorf("tx is too short")
+	}
 	binary.Read(buf, binary.BigEndian, &tx.PrevHashHeight)
 	// TODO: get hash for prevheight
 
 	binary.Read(buf, binary.BigEndian, &tx.SenderID)
 
 	dataLen, _ := buf.ReadByte()
-	if int(dataLen) > 32 {
+	if int(dataLen) > MaxSenderDataLen {
 		// You might think to return here as a length over 32 should be
 		// invalid but this is what the original implementation does
-		dataLen = 32
+		dataLen = MaxSenderDataLen
+	}
+	if buf.Len() < int(dataLen)+64 {
+		return fmt.Errorf("tx is too short")
 	}
 	tx.SenderData = make([]byte, int(dataLen))
 	binary.Read(buf, binary.BigEndian, &tx.SenderData)
@@ -152,6 +257,10 @@ func (tx *Tx) SerializedLen() int {
 	// type (1) + timestamp (8) + amount (8) + recipient pubk (32)
 	// + prevhashheight (8) + sender pubk (32) + sender sig (64)
 	// + senderdata len (1) + senderdata (0-32)
+	// coingeneration txes end after the recipient
+	if int(tx.Type) == 0 {
+		return 49
+	}
 	return 154 + len(tx.SenderData)
 }
 
@@ -168,10 +277,18 @@ func (tx *Tx) Hash() crypto.Hash {
 	return crypto.DoubleSHA256(tx.Serialize())
 }
 
+// NewStandard keeps data as it is, Validate rejects it if it is longer than
+// MaxSenderDataLen. Use NewStandardStrict to fail right away. The tx is
+// timestamped with the system clock.
 func NewStandard(amount int64, recipientID crypto.PublicKey, data []byte) *Tx {
+	return NewStandardWithClock(clock.System{}, amount, recipientID, data)
+}
+
+// NewStandardWithClock is like NewStandard with the time of c
+func NewStandardWithClock(c clock.Clock, amount int64, recipientID crypto.PublicKey, data []byte) *Tx {
 	tx := &Tx{
 		Type:           byte(2),
-		Timestamp:      time.Now().Unix(),
+		Timestamp:      c.Now().UnixNano(),
 		Amount:         amount,
 		RecipientID:    recipientID,
 		PrevHashHeight: 0,
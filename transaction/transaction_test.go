@@ -1,8 +1,14 @@
 package transaction
 
 import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/qqvv/go-nyzo/clock"
 	"github.com/qqvv/go-nyzo/crypto"
 )
 
@@ -123,6 +129,30 @@ func TestTxSerialization(t *testing.T) {
 	}
 }
 
+// coin generation txes end after the recipient, like in nyzoVerifier
+func TestCoinGenerationSerialization(t *testing.T) {
+	tx := &Tx{Type: 0, Timestamp: 1000 * 1000 * 1000, Amount: 5, RecipientID: GetValidTestKey().PubKey()}
+	b := tx.Serialize()
+	if len(b) != 49 || tx.SerializedLen() != 49 {
+		t.Fatalf("expected 49 bytes, got %v (SerializedLen %v)", len(b), tx.SerializedLen())
+	}
+
+	decoded := &Tx{}
+	if err := decoded.Deserialize(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, tx) {
+		t.Errorf("expected %+v, got %+v", tx, decoded)
+	}
+
+	// the fields after the recipient aren't read either, so they follow
+	// as the next tx in a block
+	buf := bytes.NewBuffer(append(b, 0xff))
+	if err := decoded.Deserialize(buf); err != nil || buf.Len() != 1 {
+		t.Errorf("expected 1 byte left, got %v (%v)", buf.Len(), err)
+	}
+}
+
 func TestTxValidate(t *testing.T) {
 	tests := []struct {
 		tx    *Tx
@@ -214,3 +244,283 @@ func TestTxValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestTxJSON(t *testing.T) {
+	tx := NewStandard(10, GetInvalidTestKey().PubKey(), []byte("data"))
+	tx.PrevHashHeight = 5
+	tx.PrevHash = crypto.DoubleSHA256([]byte("prev"))
+	tx.Sign(GetValidTestKey())
+
+	b, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newTx := &Tx{}
+	if err := json.Unmarshal(b, newTx); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tx, newTx) {
+		t.Errorf("JSON round trip does not match: %+v != %+v", tx, newTx)
+	}
+	if valid, err := newTx.Validate(); !valid {
+		t.Errorf("Tx from JSON is not valid: %v", err)
+	}
+
+	// ids can also be given with dashes
+	dashed := strings.Replace(string(b), tx.SenderID.String(), tx.SenderID.StringWithDashes(), 1)
+	newTx = &Tx{}
+	if err := json.Unmarshal([]byte(dashed), newTx); err != nil {
+		t.Fatal(err)
+	}
+	if newTx.SenderID != tx.SenderID {
+		t.Error("dashed SenderID does not match")
+	}
+}
+
+func TestTxNyzoString(t *testing.T) {
+	tx := NewStandard(10, GetInvalidTestKey().PubKey(), []byte("data"))
+	tx.Sign(GetValidTestKey())
+
+	s := tx.NyzoString()
+	if !strings.HasPrefix(s, "tx__") {
+		t.Errorf("unexpected prefix: %v", s)
+	}
+	newTx, err := FromNyzoString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tx.Serialize(), newTx.Serialize()) {
+		t.Error("tx nyzo string round trip does not match")
+	}
+	if valid, err := newTx.Validate(); !valid {
+		t.Errorf("tx from nyzo string is not valid: %v", err)
+	}
+}
+
+func TestPrefilledData(t *testing.T) {
+	tests := []*PrefilledData{
+		{RecipientID: GetValidTestKey().PubKey(), SenderData: []byte{}},
+		{RecipientID: GetValidTestKey().PubKey(), SenderData: []byte("hello")},
+		{RecipientID: GetValidTestKey().PubKey(), SenderData: []byte("hello"), Amount: 1000000},
+	}
+
+	for i, data := range tests {
+		newData, err := ParsePrefilledData(data.NyzoString())
+		if err != nil {
+			t.Fatalf("parsing prefilled data failed (%v): %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newData) {
+			t.Errorf("prefilled data round trip does not match (%v): %+v", i, newData)
+		}
+	}
+}
+
+func TestUnsigned(t *testing.T) {
+	tx := NewStandard(10, GetInvalidTestKey().PubKey(), []byte("data"))
+	tx.SetPrevHash(100, crypto.DoubleSHA256([]byte("block 100")))
+
+	b, err := tx.MarshalUnsigned()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "senderSig") {
+		t.Error("unsigned tx contains a signature")
+	}
+
+	unsigned, err := ParseUnsigned(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned.Sign(GetValidTestKey())
+	tx.Sign(GetValidTestKey())
+
+	if !reflect.DeepEqual(tx, unsigned) {
+		t.Errorf("tx signed from the unsigned format does not match: %+v != %+v", tx, unsigned)
+	}
+	if valid, err := unsigned.Validate(); !valid {
+		t.Error(err)
+	}
+
+	invalid := []string{
+		`{`,
+		`{"version": 2, "type": 2}`,
+		`{"version": 1, "type": 0}`,
+		`{"version": 1, "type": 2, "senderData": "` +
+			strings.Repeat("A", 48) + `"}`,
+	}
+	for i, s := range invalid {
+		if _, err := ParseUnsigned([]byte(s)); err == nil {
+			t.Errorf("expected parsing unsigned tx to fail (%v)", i)
+		}
+	}
+
+	tx.PrevHash = crypto.Hash{}
+	if _, err := tx.MarshalUnsigned(); err == nil {
+		t.Error("expected marshalling without a prev hash to fail")
+	}
+}
+
+func TestSenderData(t *testing.T) {
+	tests := []struct {
+		s     string
+		data  []byte
+		valid bool
+	}{
+		{"", []byte{}, true},
+		{"hello", []byte("hello"), true},
+		{"ünïcödé", []byte("ünïcödé"), true},
+		{strings.Repeat("a", 32), []byte(strings.Repeat("a", 32)), true},
+		{strings.Repeat("a", 33), nil, false},
+		{"X(00ff)", []byte{0x00, 0xff}, true},
+		{"X(00-ff)", []byte{0x00, 0xff}, true},
+		{"X(0)", nil, false},
+		{"X(" + strings.Repeat("00", 33) + ")", nil, false},
+	}
+
+	for i, test := range tests {
+		data, err := ParseSenderData(test.s)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("expected parsing sender data to fail (%v)", i)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(data, test.data) {
+			t.Errorf("unexpected sender data (%v): %v %v", i, data, err)
+			continue
+		}
+		// rendering and parsing again gives the same data
+		data, err = ParseSenderData(SenderDataString(data))
+		if err != nil || !reflect.DeepEqual(data, test.data) {
+			t.Errorf("sender data round trip does not match (%v): %v %v", i, data, err)
+		}
+	}
+
+	if s := SenderDataString([]byte{0x00, 0x01}); s != "X(0001)" {
+		t.Errorf("expected non-printable data as hex, got %v", s)
+	}
+	if s := SenderDataString([]byte("X(ab)")); s != "X(5828616229)" {
+		t.Errorf("expected text in the hex format as hex, got %v", s)
+	}
+}
+
+func TestSenderDataLength(t *testing.T) {
+	long := make([]byte, MaxSenderDataLen+1)
+
+	if _, err := NewStandardStrict(1, GetInvalidTestKey().PubKey(), long); err != ErrSenderDataTooLong {
+		t.Errorf("expected ErrSenderDataTooLong, got %v", err)
+	}
+	// NewStandard leaves the data alone so Validate rejects it
+	tx := NewStandard(1, GetInvalidTestKey().PubKey(), long)
+	if len(tx.SenderData) != len(long) {
+		t.Errorf("expected sender data to be kept, got %v bytes", len(tx.SenderData))
+	}
+	tx.Sign(GetValidTestKey())
+	if valid, err := tx.Validate(); valid || err != ErrSenderDataTooLong {
+		t.Errorf("expected ErrSenderDataTooLong from Validate, got %v", err)
+	}
+	if err := tx.SetSenderData(long); err != ErrSenderDataTooLong {
+		t.Errorf("expected ErrSenderDataTooLong, got %v", err)
+	}
+
+	tx.SenderData = long
+	tx.Sign(GetValidTestKey())
+	if valid, _ := tx.Validate(); valid {
+		t.Error("expected tx with too long sender data to be invalid")
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	txs := []*Tx{}
+	for i := 0; i < 10; i++ {
+		tx := NewStandard(int64(i), GetInvalidTestKey().PubKey(), nil)
+		tx.Sign(GetValidTestKey())
+		txs = append(txs, tx)
+	}
+	txs[5].SenderSig[0] ^= 0xff
+	txs[7].Type = 0
+
+	// must match validating one by one
+	for i, err := range ValidateAll(txs) {
+		valid, expected := txs[i].Validate()
+		if valid != (err == nil) || (err != nil && err.Error() != expected.Error()) {
+			t.Errorf("ValidateAll differs from Validate (%v): %v != %v", i, err, expected)
+		}
+	}
+}
+
+func TestSigCache(t *testing.T) {
+	prevCache := SigCache
+	defer func() { SigCache = prevCache }()
+	SigCache = crypto.NewSigCache(100)
+
+	tx := NewStandard(1, GetInvalidTestKey().PubKey(), nil)
+	tx.Sign(GetValidTestKey())
+	other := NewStandard(2, GetInvalidTestKey().PubKey(), nil)
+	other.Sign(GetValidTestKey())
+
+	tx.Validate()
+	ValidateAll([]*Tx{tx, other})
+	tx.Validate()
+	other.Validate()
+
+	stats := SigCache.Stats()
+	if stats.Misses != 2 || stats.Hits != 3 {
+		t.Errorf("unexpected sig cache stats %+v", stats)
+	}
+
+	// a changed tx must not hit the cache
+	tx.Amount = 5
+	if valid, _ := tx.Validate(); valid {
+		t.Error("expected changed tx to be invalid")
+	}
+
+	SigCache = nil
+	if errs := ValidateAll([]*Tx{other}); errs[0] != nil {
+		t.Error(errs[0])
+	}
+}
+
+func FuzzTxDeserialize(f *testing.F) {
+	standard := NewStandard(10, GetInvalidTestKey().PubKey(), []byte("data"))
+	standard.Sign(GetValidTestKey())
+	seed := &Tx{Type: 1, Amount: 1, RecipientID: GetValidTestKey().PubKey()}
+	seed.Sign(GetValidTestKey())
+	coinGen := &Tx{Type: 0, Amount: 1, RecipientID: GetValidTestKey().PubKey()}
+
+	for _, tx := range []*Tx{standard, seed, coinGen} {
+		b := tx.Serialize()
+		f.Add(b)
+		f.Add(b[:len(b)-1])
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0x03})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		buf := bytes.NewBuffer(data)
+		tx := &Tx{}
+		if err := tx.Deserialize(buf); err != nil {
+			return
+		}
+		if len(tx.SenderData) > MaxSenderDataLen {
+			t.Fatalf("sender data of %v bytes", len(tx.SenderData))
+		}
+
+		expected := append([]byte{}, data[:len(data)-buf.Len()]...)
+		// a sender data length over 32 is read as 32
+		if tx.Type != 0 && expected[89] > MaxSenderDataLen {
+			expected[89] = MaxSenderDataLen
+		}
+		if b := tx.Serialize(); !bytes.Equal(b, expected) {
+			t.Fatalf("re-encoded tx differs\nexpected %x\ngot      %x", expected, b)
+		}
+	})
+}
+
+func TestNewStandardTimestamp(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 7, 0, time.UTC)
+	tx := NewStandardWithClock(clock.NewManual(now), 1, GetValidTestKey().PubKey(), nil)
+	if tx.Timestamp != now.UnixNano() {
+		t.Errorf("unexpected tx timestamp %v", tx.Timestamp)
+	}
+}
@@ -0,0 +1,77 @@
+package transaction
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/qqvv/go-nyzo/crypto"
+)
+
+const unsignedVersion = 1
+
+// unsignedFile is the portable format of a tx that is prepared online and
+// signed on another machine. It holds everything ForSigning needs except
+// the SenderID, which comes from the signing key.
+type unsignedFile struct {
+	Version        int              `json:"version"`
+	Type           byte             `json:"type"`
+	Timestamp      int64            `json:"timestamp"`
+	Amount         int64            `json:"amount"`
+	RecipientID    crypto.PublicKey `json:"recipientId"`
+	PrevHashHeight int64            `json:"prevHashHeight"`
+	PrevHash       crypto.Hash      `json:"prevHash"`
+	SenderData     []byte           `json:"senderData"`
+}
+
+// SetPrevHash sets the block the tx refers to, the hash is signed while
+// only the height is serialized
+func (tx *Tx) SetPrevHash(height int64, hash crypto.Hash) {
+	tx.PrevHashHeight = height
+	tx.PrevHash = hash
+}
+
+// MarshalUnsigned encodes the tx in the portable unsigned format, any
+// sender or signature is left out
+func (tx *Tx) MarshalUnsigned() ([]byte, error) {
+	if tx.PrevHashHeight != 0 && tx.PrevHash == (crypto.Hash{}) {
+		return nil, fmt.Errorf("prev hash for height %v is not set", tx.PrevHashHeight)
+	}
+	return json.MarshalIndent(&unsignedFile{
+		Version:        unsignedVersion,
+		Type:           tx.Type,
+		Timestamp:      tx.Timestamp,
+		Amount:         tx.Amount,
+		RecipientID:    tx.RecipientID,
+		PrevHashHeight: tx.PrevHashHeight,
+		PrevHash:       tx.PrevHash,
+		SenderData:     tx.SenderData,
+	}, "", "  ")
+}
+
+// ParseUnsigned decodes a tx in the portable unsigned format, it is ready
+// to be signed with Sign
+func ParseUnsigned(b []byte) (*Tx, error) {
+	f := &unsignedFile{}
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil, fmt.Errorf("invalid unsigned tx: %v", err)
+	}
+	if f.Version != unsignedVersion {
+		return nil, fmt.Errorf("unsupported unsigned tx version %v", f.Version)
+	}
+	if f.Type != 1 && f.Type != 2 {
+		return nil, fmt.Errorf("only seed (1) and standard (2) txes can be signed")
+	}
+	if len(f.SenderData) > MaxSenderDataLen {
+		return nil, ErrSenderDataTooLong
+	}
+
+	return &Tx{
+		Type:           f.Type,
+		Timestamp:      f.Timestamp,
+		Amount:         f.Amount,
+		RecipientID:    f.RecipientID,
+		PrevHashHeight: f.PrevHashHeight,
+		PrevHash:       f.PrevHash,
+		SenderData:     f.SenderData,
+	}, nil
+}
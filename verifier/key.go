@@ -0,0 +1,59 @@
+package verifier
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/qqvv/go-nyzo/crypto"
+	"github.com/qqvv/go-nyzo/files"
+)
+
+const PrivateSeedFile = "verifier_private_seed"
+
+// LoadPrivateKey reads the verifier key from the private seed file in the
+// data directory. It fails if the file is readable by anyone but the owner.
+func LoadPrivateKey() (crypto.PrivateKey, error) {
+	mode, err := files.Mode(PrivateSeedFile)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	// windows doesn't have unix permissions
+	if runtime.GOOS != "windows" && mode.Perm()&0077 != 0 {
+		return crypto.PrivateKey{}, fmt.Errorf(
+			"permissions %v of %v are too open, it must only be accessible by the owner (0600)",
+			mode.Perm(), PrivateSeedFile)
+	}
+
+	s, err := files.ReadString(PrivateSeedFile)
+	if err != nil {
+		return crypto.PrivateKey{}, err
+	}
+	privKey, err := crypto.ParsePrivateKey(s)
+	if err != nil {
+		return crypto.PrivateKey{}, fmt.Errorf("invalid %v: %v", PrivateSeedFile, err)
+	}
+	return privKey, nil
+}
+
+// CreatePrivateKey generates a new verifier key and writes it to the
+// private seed file. An existing seed is never overwritten.
+func CreatePrivateKey() (crypto.PrivateKey, error) {
+	privKey := crypto.GenPrivKey()
+	err := files.Create(PrivateSeedFile, []byte(privKey.StringWithDashes()+"\n"), 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return crypto.PrivateKey{}, fmt.Errorf("refusing to overwrite existing %v", PrivateSeedFile)
+		}
+		return crypto.PrivateKey{}, err
+	}
+	return privKey, nil
+}
+
+// LoadOrCreatePrivateKey loads the verifier key, creating it on first start
+func LoadOrCreatePrivateKey() (crypto.PrivateKey, error) {
+	if !files.Exists(PrivateSeedFile) {
+		return CreatePrivateKey()
+	}
+	return LoadPrivateKey()
+}
@@ -0,0 +1,64 @@
+package verifier
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/qqvv/go-nyzo/files"
+	"github.com/qqvv/go-nyzo/files/filestest"
+)
+
+func TestPrivateKeyFile(t *testing.T) {
+	dir := filestest.SetDataDir(t)
+
+	if _, err := LoadPrivateKey(); err == nil {
+		t.Error("expected loading a missing key to fail")
+	}
+
+	privKey, err := LoadOrCreatePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadOrCreatePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded != privKey {
+		t.Error("loaded key does not match created key")
+	}
+
+	if _, err := CreatePrivateKey(); err == nil {
+		t.Error("expected creating a key over an existing one to fail")
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, PrivateSeedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected permissions 0600, got %v", fi.Mode().Perm())
+	}
+
+	os.Chmod(filepath.Join(dir, PrivateSeedFile), 0644)
+	if _, err := LoadPrivateKey(); err == nil {
+		t.Error("expected loading a world readable key to fail")
+	}
+}
+
+func TestInvalidPrivateKeyFile(t *testing.T) {
+	filestest.SetDataDir(t)
+
+	if err := files.WriteMode(PrivateSeedFile, []byte("0123-4567\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadPrivateKey(); err == nil {
+		t.Error("expected loading an invalid key to fail")
+	}
+}
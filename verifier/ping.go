@@ -0,0 +1,49 @@
+package verifier
+
+import (
+	"net"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/message"
+)
+
+// Version is reported in PingResponses, releases set it with
+// -ldflags "-X github.com/qqvv/go-nyzo/verifier.Version=..."
+var Version = "dev"
+
+type Chain interface {
+	FrozenEdgeHeight() (int64, error)
+}
+
+// Pinger answers Pings with the version, frozen edge and uptime of the node
+type Pinger struct {
+	// Clock is the time the responses are timestamped with, the system
+	// clock unless set. The uptime always uses the local monotonic time.
+	Clock clock.Clock
+
+	chain   Chain
+	started time.Time
+}
+
+// NewPinger counts the uptime from now, chain may be nil
+func NewPinger(chain Chain) *Pinger {
+	return &Pinger{Clock: clock.System{}, chain: chain, started: time.Now()}
+}
+
+func (p *Pinger) HandlePing(msg *message.Msg, addr net.Addr) *message.Msg {
+	content := &message.PingResponseContent{
+		Version:    Version,
+		FrozenEdge: -1,
+		Uptime:     int64(time.Since(p.started)),
+	}
+	if p.chain != nil {
+		if height, err := p.chain.FrozenEdgeHeight(); err == nil {
+			content.FrozenEdge = height
+		}
+	}
+
+	resp := message.NewWithClock(p.Clock, int(message.PingResponse))
+	resp.Content = content.Serialize()
+	return resp
+}
@@ -0,0 +1,55 @@
+package verifier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qqvv/go-nyzo/clock"
+	"github.com/qqvv/go-nyzo/message"
+)
+
+type testChain int64
+
+func (c testChain) FrozenEdgeHeight() (int64, error) {
+	if c < 0 {
+		return 0, errors.New("no blocks")
+	}
+	return int64(c), nil
+}
+
+func TestHandlePing(t *testing.T) {
+	t.Parallel()
+	c := clock.NewManual(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		chain    Chain
+		expected int64
+	}{
+		{nil, -1},
+		{testChain(-1), -1},
+		{testChain(100), 100},
+	}
+
+	for i, test := range tests {
+		p := NewPinger(test.chain)
+		p.Clock = c
+		p.started = p.started.Add(-time.Minute)
+
+		resp := p.HandlePing(message.New(int(message.Ping)), nil)
+		if resp.Type != message.PingResponse {
+			t.Errorf("unexpected response type %v (%v)", resp.Type, i)
+		}
+		content := &message.PingResponseContent{}
+		if err := content.Deserialize(resp.Content); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Timestamp != c.Now().UnixNano() {
+			t.Errorf("unexpected response timestamp %v (%v)", resp.Timestamp, i)
+		}
+		if content.Version != Version || content.FrozenEdge != test.expected ||
+			content.Uptime < int64(time.Minute) || content.Uptime > int64(2*time.Minute) {
+			t.Errorf("unexpected response %+v (%v)", content, i)
+		}
+	}
+}